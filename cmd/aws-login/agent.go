@@ -0,0 +1,408 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"sync"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awscredentials "github.com/aws/aws-sdk-go-v2/credentials"
+
+	"github.com/michalschott/aws-login/pkg/agent"
+	"github.com/michalschott/aws-login/pkg/awsconfig"
+	"github.com/michalschott/aws-login/pkg/format"
+	"github.com/michalschott/aws-login/pkg/policy"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// agentRefreshInterval is how often the agent looks for role sessions to
+// renew.
+const agentRefreshInterval = time.Minute
+
+func agentCommand(flags *flag.FlagSet) func([]string) error {
+	o := &options{}
+	o.registerSession(flags)
+	Socket := flags.String("socket", "", "Agent socket, defaults to AWS_LOGIN_AGENT_SOCK or aws-login/agent.sock in the runtime directory")
+	Role := flags.String("role", "", "Only revoke sessions of this role")
+	Profile := flags.String("profile", "", "Only revoke sessions of this profile")
+
+	return func(args []string) error {
+		command := "start"
+		if len(args) > 0 {
+			command = args[0]
+			// flags may also follow the subcommand
+			if err := flags.Parse(args[1:]); err != nil {
+				return err
+			}
+			if flags.NArg() > 0 {
+				return withClass(errUsage, fmt.Errorf("unexpected arguments: %v", flags.Args()))
+			}
+		}
+
+		path := *Socket
+		if path == "" {
+			var err error
+			if path, err = agent.SocketPath(); err != nil {
+				return err
+			}
+		}
+
+		switch command {
+		case "start":
+			return agentStart(o, path)
+		case "list":
+			return agentList(path)
+		case "revoke":
+			return agentRevoke(path, *Role, *Profile)
+		default:
+			return withClass(errUsage, fmt.Errorf("unknown agent command %q, expected start, list or revoke", command))
+		}
+	}
+}
+
+func agentStart(o *options, path string) error {
+	// the agent keeps sessions in memory only
+	o.NoCache = true
+	if err := o.prepare(); err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	cfg, err := o.config(ctx)
+	if err != nil {
+		return err
+	}
+	a := &agentState{
+		base:      &session{o: o, cfg: cfg},
+		profile:   os.Getenv("AWS_PROFILE"),
+		region:    o.region,
+		mfaSerial: o.configuredMfaSerial(),
+		roles:     map[string]*roleSession{},
+	}
+
+	// log in right away, so MFA is prompted for when the agent starts
+	if _, err := a.base.get(ctx); err != nil {
+		return err
+	}
+
+	listener, err := agent.Listen(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(path) }()
+
+	go func() {
+		<-ctx.Done()
+		_ = listener.Close()
+	}()
+	go a.refreshLoop(ctx)
+
+	fmt.Printf("AWS_LOGIN_AGENT_SOCK=%s\n", path)
+	log.Infof("Agent listening on %s, stop with Ctrl-C.", path)
+
+	if err := agent.Serve(listener, a.handle); err != nil && ctx.Err() == nil {
+		return err
+	}
+	return nil
+}
+
+func agentList(path string) error {
+	response, err := agentDo(path, agent.Request{Op: agent.OpList})
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "ROLE\tPROFILE\tEXPIRES")
+	for _, s := range response.Sessions {
+		role, profile := s.Role, s.Profile
+		if role == "" {
+			role = "-"
+		}
+		if profile == "" {
+			profile = "-"
+		}
+		expires := s.Expiration.Local().Format(time.RFC3339)
+		if time.Now().After(s.Expiration) {
+			expires += " (expired)"
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\n", role, profile, expires)
+	}
+	return w.Flush()
+}
+
+func agentRevoke(path, role, profile string) error {
+	response, err := agentDo(path, agent.Request{Op: agent.OpRevoke, Role: role, Profile: profile})
+	if err != nil {
+		return err
+	}
+	log.Infof("Revoked %d sessions.", response.Revoked)
+	return nil
+}
+
+func agentDo(path string, r agent.Request) (*agent.Response, error) {
+	client, err := agent.Dial(path)
+	if err != nil {
+		return nil, fmt.Errorf("no agent running on %s: %w", path, err)
+	}
+	defer func() { _ = client.Close() }()
+
+	return client.Do(r)
+}
+
+// agentState holds the base session of the agent and the roles assumed with
+// it. profile, region and mfaSerial are the base settings the agent was
+// started with.
+type agentState struct {
+	base      *session
+	profile   string
+	region    string
+	mfaSerial string
+
+	mu    sync.Mutex
+	roles map[string]*roleSession
+}
+
+type roleSession struct {
+	request     agent.Request
+	credentials format.Credentials
+}
+
+func (a *agentState) handle(ctx context.Context, r agent.Request) agent.Response {
+	switch r.Op {
+	case agent.OpCredentials:
+		if err := a.match(r); err != nil {
+			return agent.Response{Error: err.Error(), ErrorClass: agent.ClassMismatch}
+		}
+		c, err := a.credentials(ctx, r)
+		if err != nil {
+			response := agent.Response{Error: err.Error()}
+			if class := classify(err); class != nil {
+				response.ErrorClass = class.name
+			}
+			return response
+		}
+		credentials := agent.Credentials(c)
+		return agent.Response{Credentials: &credentials}
+	case agent.OpList:
+		return agent.Response{Sessions: a.list()}
+	case agent.OpRevoke:
+		return agent.Response{Revoked: a.revoke(r.Role, r.Profile)}
+	default:
+		return agent.Response{Error: fmt.Sprintf("unknown operation %q", r.Op)}
+	}
+}
+
+// match checks that the client of r uses the base credentials of the agent,
+// so a shell on another profile or region is not handed its sessions.
+func (a *agentState) match(r agent.Request) error {
+	switch {
+	case r.BaseProfile != a.profile:
+		return fmt.Errorf("the client uses profile %s, the agent holds %s", r.BaseProfile, a.profile)
+	case r.Region != a.region:
+		return fmt.Errorf("the client uses region %q, the agent %q", r.Region, a.region)
+	case r.MfaSerial != "" && r.MfaSerial != a.mfaSerial:
+		return fmt.Errorf("the client uses MFA device %s, the agent %q", r.MfaSerial, a.mfaSerial)
+	}
+	return nil
+}
+
+// credentials returns the base session for requests without a role and
+// assumes the requested role with it otherwise.
+func (a *agentState) credentials(ctx context.Context, r agent.Request) (format.Credentials, error) {
+	base, err := a.base.get(ctx)
+	if err != nil {
+		return format.Credentials{}, err
+	}
+	if r.Role == "" && r.Profile == "" {
+		return base, nil
+	}
+
+	key := requestKey(r)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if s := a.roles[key]; s != nil && time.Until(s.credentials.Expiration) > a.base.o.RefreshWindow {
+		return s.credentials, nil
+	}
+
+	c, err := a.assume(ctx, r, base)
+	if err != nil {
+		return format.Credentials{}, err
+	}
+	log.Infof("Assumed %s%s, expiring at %s.", r.Role, r.Profile, c.Expiration.Local().Format(time.RFC3339))
+
+	a.roles[key] = &roleSession{request: r, credentials: c}
+	return c, nil
+}
+
+// assume logs in to the requested role with the base session, which is
+// already authenticated with MFA.
+func (a *agentState) assume(ctx context.Context, r agent.Request, base format.Credentials) (format.Credentials, error) {
+	o := &options{
+		Role:            r.Role,
+		Account:         r.Account,
+		RoleSessionName: r.SessionName,
+		Duration:        durations{3600},
+		NoCache:         true,
+		RefreshWindow:   a.base.o.RefreshWindow,
+		SourceIdentity:  sourceIdentity(r.SourceIdentity),
+		ExternalID:      r.ExternalID,
+		policy:          &policy.Policy{Document: r.Policy, Arns: r.PolicyArns},
+		network:         a.base.o.network,
+		mfaDone:         true,
+	}
+	if len(r.Duration) > 0 {
+		o.Duration = durations(r.Duration)
+	}
+	for _, tag := range r.Tags {
+		if err := o.Tags.Set(tag); err != nil {
+			return format.Credentials{}, withClass(errUsage, err)
+		}
+	}
+	for _, tag := range r.TransitiveTags {
+		if err := o.TransitiveTags.Set(tag); err != nil {
+			return format.Credentials{}, withClass(errUsage, err)
+		}
+	}
+
+	if r.Profile != "" {
+		if r.Role != "" {
+			return format.Credentials{}, withClass(errUsage, errors.New("-profile and -role can not be used together"))
+		}
+		roles, err := a.profileRoles(r.Profile)
+		if err != nil {
+			return format.Credentials{}, err
+		}
+		o.profileRoles = roles
+	}
+
+	cfg := a.base.cfg.Copy()
+	cfg.Credentials = aws.NewCredentialsCache(awscredentials.NewStaticCredentialsProvider(base.AccessKeyID, base.SecretAccessKey, base.SessionToken))
+
+	c, err := o.login(ctx, cfg)
+	if err != nil {
+		return format.Credentials{}, err
+	}
+	return c.Format(), nil
+}
+
+// profileRoles resolves the roles of profile, which has to use the base
+// credentials of the agent.
+func (a *agentState) profileRoles(profile string) ([]awsconfig.RoleProfile, error) {
+	path, err := awsconfig.ConfigPath()
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := awsconfig.Load(path)
+	if err != nil {
+		return nil, err
+	}
+
+	source, roles, err := f.ResolveRole(profile)
+	if err != nil {
+		return nil, err
+	}
+	if source != a.profile {
+		return nil, withClass(errConfig, fmt.Errorf("profile %s uses base credentials of profile %s, the agent holds %s", profile, source, a.profile))
+	}
+	return roles, nil
+}
+
+// refreshLoop renews role sessions before they expire, as long as the base
+// session is valid. Once it expires, MFA is prompted for on the next request.
+func (a *agentState) refreshLoop(ctx context.Context) {
+	ticker := time.NewTicker(agentRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			a.refresh(ctx)
+		}
+	}
+}
+
+func (a *agentState) refresh(ctx context.Context) {
+	if !a.base.valid() {
+		return
+	}
+
+	a.mu.Lock()
+	expiring := []agent.Request{}
+	for _, s := range a.roles {
+		if time.Until(s.credentials.Expiration) <= a.base.o.RefreshWindow {
+			expiring = append(expiring, s.request)
+		}
+	}
+	a.mu.Unlock()
+
+	for _, r := range expiring {
+		if _, err := a.credentials(ctx, r); err != nil {
+			log.Warnf("Can not renew %s%s: %v", r.Role, r.Profile, err)
+		}
+	}
+}
+
+func (a *agentState) list() []agent.Session {
+	sessions := []agent.Session{}
+	if c := a.base.current(); c != nil {
+		sessions = append(sessions, agent.Session{Expiration: c.expiration})
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	keys := make([]string, 0, len(a.roles))
+	for key := range a.roles {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		s := a.roles[key]
+		sessions = append(sessions, agent.Session{Role: s.request.Role, Profile: s.request.Profile, Expiration: s.credentials.Expiration})
+	}
+	return sessions
+}
+
+// revoke forgets the sessions of role or profile. Without either it forgets
+// all sessions, including the base one.
+func (a *agentState) revoke(role, profile string) int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	revoked := 0
+	for key, s := range a.roles {
+		if (role == "" && profile == "") || (role != "" && s.request.Role == role) || (profile != "" && s.request.Profile == profile) {
+			delete(a.roles, key)
+			revoked++
+		}
+	}
+
+	if role == "" && profile == "" && a.base.forget() {
+		revoked++
+	}
+	return revoked
+}
+
+// requestKey identifies the session a request is answered with.
+func requestKey(r agent.Request) string {
+	r.Op = ""
+	b, _ := json.Marshal(r) // #nosec G104 -- a struct of strings and ints always marshals
+	return string(b)
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/michalschott/aws-login/pkg/agent"
+)
+
+func TestAgentMatch(t *testing.T) {
+	a := &agentState{profile: "dev", region: "eu-west-1", mfaSerial: "arn:aws:iam::111111111111:mfa/alice"}
+
+	tests := []struct {
+		request agent.Request
+		wantErr bool
+	}{
+		{request: agent.Request{BaseProfile: "dev", Region: "eu-west-1"}},
+		{request: agent.Request{BaseProfile: "dev", Region: "eu-west-1", MfaSerial: "arn:aws:iam::111111111111:mfa/alice"}},
+		{request: agent.Request{BaseProfile: "prod", Region: "eu-west-1"}, wantErr: true},
+		{request: agent.Request{BaseProfile: "dev"}, wantErr: true},
+		{request: agent.Request{BaseProfile: "dev", Region: "us-east-1"}, wantErr: true},
+		{request: agent.Request{BaseProfile: "dev", Region: "eu-west-1", MfaSerial: "arn:aws:iam::111111111111:mfa/bob"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		if err := a.match(tt.request); (err != nil) != tt.wantErr {
+			t.Errorf("err is wrong, value=%+v, wantErr=%v, err=%v", tt.request, tt.wantErr, err)
+		}
+	}
+}
+
+// A client on another profile than the agent logs in directly instead of
+// getting the sessions of the agent.
+func TestFromAgentProfileMismatch(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Chmod(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "agent.sock")
+	listener, err := agent.Listen(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = listener.Close() }()
+
+	// the base session is never used, a request reaching it would panic
+	a := &agentState{profile: "dev", roles: map[string]*roleSession{}}
+	go func() { _ = agent.Serve(listener, a.handle) }()
+
+	t.Setenv("AWS_LOGIN_AGENT_SOCK", path)
+	t.Setenv("AWS_PROFILE", "prod")
+
+	for _, o := range []*options{{}, {Role: "Admin"}} {
+		credentials, err := o.fromAgent()
+		if credentials != nil || err != nil {
+			t.Errorf("role %q: got credentials=%v, err=%v, expected a direct login", o.Role, credentials, err)
+		}
+	}
+
+	// the agent refuses before its base session is used
+	response := a.handle(context.Background(), agent.Request{Op: agent.OpCredentials, BaseProfile: "prod"})
+	if response.ErrorClass != agent.ClassMismatch {
+		t.Errorf("got %+v", response)
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/michalschott/aws-login/pkg/cache"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func cacheCommand(flags *flag.FlagSet) func([]string) error {
+	Role := flags.String("role", "", "Only clear sessions of this role, given as a name or ARN")
+
+	return func(args []string) error {
+		if len(args) == 0 {
+			return withClass(errUsage, errors.New("expected list or clear"))
+		}
+
+		// flags may also follow the subcommand
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+
+		sessionCache, err := cache.New()
+		if err != nil {
+			return err
+		}
+
+		switch args[0] {
+		case "list":
+			return cacheList(sessionCache)
+		case "clear":
+			removed, err := sessionCache.Clear(*Role)
+			if err != nil {
+				return err
+			}
+			log.Infof("Removed %d cached sessions.", removed)
+			return nil
+		default:
+			return withClass(errUsage, fmt.Errorf("unknown cache command %q, expected list or clear", args[0]))
+		}
+	}
+}
+
+func cacheList(sessionCache *cache.Cache) error {
+	entries, err := sessionCache.List()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "SOURCE\tROLE\tMFA\tDURATION\tEXPIRES")
+	for _, e := range entries {
+		role := e.Key.RoleArns()
+		if role == "" {
+			role = "-"
+		}
+		mfa := "no"
+		if e.Key.MfaSerial != "" {
+			mfa = "yes"
+		}
+		expires := e.Expiration.Local().Format(time.RFC3339)
+		if time.Now().After(e.Expiration) {
+			expires += " (expired)"
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\n", e.Key.SourceIdentity, role, mfa, e.Key.Duration, expires)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/michalschott/aws-login/pkg/awsconfig"
+	"github.com/michalschott/aws-login/pkg/cache"
+	"github.com/michalschott/aws-login/pkg/identity"
+	"github.com/michalschott/aws-login/pkg/login"
+	"github.com/michalschott/aws-login/pkg/random"
+)
+
+// maxChainedDuration is the longest session STS issues for a role assumed
+// with credentials of another role.
+const maxChainedDuration = 3600
+
+// durations is a comma separated list of session durations, one per hop of a
+// role chain. The last value applies to all remaining hops.
+type durations []int
+
+func (d *durations) String() string {
+	values := make([]string, len(*d))
+	for i, v := range *d {
+		values[i] = strconv.Itoa(v)
+	}
+	return strings.Join(values, ",")
+}
+
+func (d *durations) Set(value string) error {
+	parsed := durations{}
+	for _, v := range strings.Split(value, ",") {
+		seconds, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", v)
+		}
+		if seconds <= 0 {
+			return withClass(errUsage, fmt.Errorf("invalid duration %q, expected a positive number of seconds", v))
+		}
+		parsed = append(parsed, seconds)
+	}
+	*d = parsed
+	return nil
+}
+
+// hop returns the duration of the i-th hop.
+func (d durations) hop(i int) int {
+	if i < len(d) {
+		return d[i]
+	}
+	return d[len(d)-1]
+}
+
+// tags are session tags given as KEY=VALUE, comma separated or by repeating
+// the flag.
+type tags []login.Tag
+
+func (t *tags) String() string {
+	return strings.Join(t.pairs(), ",")
+}
+
+func (t *tags) Set(value string) error {
+	parsed, err := parseTags(value)
+	if err != nil {
+		return err
+	}
+	*t = append(*t, parsed...)
+	return nil
+}
+
+// pairs returns the tags as KEY=VALUE.
+func (t tags) pairs() []string {
+	values := make([]string, len(t))
+	for i, tag := range t {
+		values[i] = tag.Key + "=" + tag.Value
+	}
+	return values
+}
+
+// keys returns the keys of the tags.
+func (t tags) keys() []string {
+	keys := make([]string, len(t))
+	for i, tag := range t {
+		keys[i] = tag.Key
+	}
+	return keys
+}
+
+// parseTags parses comma separated KEY=VALUE pairs. Tag values can not hold
+// commas.
+func parseTags(value string) (tags, error) {
+	parsed := tags{}
+	for _, pair := range strings.Split(value, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		key, v, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid tag %q, expected KEY=VALUE", pair)
+		}
+		parsed = append(parsed, login.Tag{Key: strings.TrimSpace(key), Value: strings.TrimSpace(v)})
+	}
+	return parsed, nil
+}
+
+// callerSourceIdentity stands for the name of the caller as source identity.
+const callerSourceIdentity = "true"
+
+// sourceIdentity is set to a name with -source-identity=NAME, or to the name
+// of the caller by the flag alone.
+type sourceIdentity string
+
+func (s *sourceIdentity) String() string   { return string(*s) }
+func (s *sourceIdentity) IsBoolFlag() bool { return true }
+
+func (s *sourceIdentity) Set(value string) error {
+	if value == "false" {
+		value = ""
+	}
+	*s = sourceIdentity(value)
+	return nil
+}
+
+// roleChain parses a comma separated list of roles. Every role is given as an
+// ARN, as an ACCOUNT:ROLE pair or as a role name in the default account.
+// ARNs are built in partition. Session names are matched to roles by position
+// and generated when missing.
+func roleChain(roles, sessionNames string, duration durations, partition, account string) ([]login.Role, error) {
+	names := []string{}
+	if sessionNames != "" {
+		names = strings.Split(sessionNames, ",")
+	}
+
+	chain := []login.Role{}
+	for i, role := range strings.Split(roles, ",") {
+		role = strings.TrimSpace(role)
+		if role == "" {
+			return nil, withClass(errUsage, fmt.Errorf("empty role in %q", roles))
+		}
+
+		h := login.Role{Arn: role}
+		if !strings.HasPrefix(role, "arn:") {
+			roleAccount, name, ok := strings.Cut(role, ":")
+			if !ok {
+				roleAccount, name = account, role
+			}
+			h.Arn = "arn:" + partition + ":iam::" + roleAccount + ":role/" + name
+		}
+
+		seconds, err := random.IntToInt32(duration.hop(i))
+		if err != nil {
+			return nil, err
+		}
+		h.Duration = seconds
+
+		name := ""
+		if i < len(names) {
+			name = names[i]
+		}
+		h.SessionName, err = sessionName(name)
+		if err != nil {
+			return nil, err
+		}
+
+		chain = append(chain, h)
+	}
+	return chain, nil
+}
+
+// profileChain converts role profiles of the shared config file into hops.
+// Settings missing in a profile are taken from flags.
+func profileChain(profiles []awsconfig.RoleProfile, duration durations) ([]login.Role, error) {
+	chain := []login.Role{}
+	for i, p := range profiles {
+		seconds := duration.hop(i)
+		if p.DurationSeconds != 0 {
+			seconds = p.DurationSeconds
+		}
+
+		h := login.Role{Arn: p.RoleArn, ExternalID: p.ExternalID, SourceIdentity: p.SourceIdentity}
+		sessionTags, err := parseTags(p.SessionTags)
+		if err != nil {
+			return nil, withClass(errConfig, fmt.Errorf("profile %s: session_tags: %w", p.Name, err))
+		}
+		transitiveTags, err := parseTags(p.TransitiveSessionTags)
+		if err != nil {
+			return nil, withClass(errConfig, fmt.Errorf("profile %s: transitive_session_tags: %w", p.Name, err))
+		}
+		h.Tags = append(sessionTags, transitiveTags...)
+		h.TransitiveTagKeys = transitiveTags.keys()
+
+		h.Duration, err = random.IntToInt32(seconds)
+		if err != nil {
+			return nil, err
+		}
+
+		h.SessionName, err = sessionName(p.RoleSessionName)
+		if err != nil {
+			return nil, err
+		}
+
+		chain = append(chain, h)
+	}
+	return chain, nil
+}
+
+// sessionSettings adds the session settings of the flags to chain.
+// Transitive tags and the source identity are set on the first role, so that
+// they apply to the whole chain, session tags and the external ID on the last
+// role, whose credentials are returned.
+func sessionSettings(chain []login.Role, sessionTags, transitiveTags tags, sourceIdentity, externalID string) error {
+	if len(chain) == 0 {
+		if len(sessionTags) > 0 || len(transitiveTags) > 0 || sourceIdentity != "" || externalID != "" {
+			return withClass(errUsage, errors.New("session tags, source identity and external ID need -role or -profile"))
+		}
+		return nil
+	}
+
+	first, last := &chain[0], &chain[len(chain)-1]
+	first.Tags = append(first.Tags, transitiveTags...)
+	first.TransitiveTagKeys = append(first.TransitiveTagKeys, transitiveTags.keys()...)
+	if sourceIdentity != "" {
+		first.SourceIdentity = sourceIdentity
+	}
+	last.Tags = append(last.Tags, sessionTags...)
+	if externalID != "" {
+		last.ExternalID = externalID
+	}
+
+	for _, h := range chain {
+		if err := h.Validate(); err != nil {
+			return withClass(errUsage, fmt.Errorf("role %s: %w", h.Arn, err))
+		}
+	}
+	return nil
+}
+
+// cacheRoles returns the roles of chain for the cache key, so that chains
+// through other roles or with other settings do not share a session.
+func cacheRoles(chain []login.Role) []cache.Role {
+	roles := []cache.Role{}
+	for _, h := range chain {
+		r := cache.Role{Arn: h.Arn, Duration: int(h.Duration)}
+		if h.ExternalID != "" || h.SourceIdentity != "" || len(h.Tags) > 0 || !h.Policy.IsZero() {
+			r.Settings = strings.Join([]string{
+				h.ExternalID,
+				h.SourceIdentity,
+				strings.Join(tags(h.Tags).pairs(), ","),
+				strings.Join(h.TransitiveTagKeys, ","),
+				h.Policy.Document,
+				strings.Join(h.Policy.Arns, ","),
+			}, ";")
+		}
+		roles = append(roles, r)
+	}
+	return roles
+}
+
+// callerName returns the name of the caller, used as its source identity.
+func callerName(callerArn string) (string, error) {
+	p, err := identity.Parse(callerArn)
+	if err != nil {
+		return "", err
+	}
+	switch p.Type {
+	case identity.TypeUser, identity.TypeFederatedUser:
+		return p.Name, nil
+	case identity.TypeAssumedRole:
+		return p.SessionName, nil
+	}
+	return "", fmt.Errorf("%s has no user name", callerArn)
+}
+
+// sessionName returns name, or a random one when it is empty.
+func sessionName(name string) (string, error) {
+	if name = strings.TrimSpace(name); name != "" {
+		return name, nil
+	}
+
+	randomStringConfig := random.RandomStringConfig{
+		Length:  16,
+		Charset: "abcdefghijklmnopqrstuvwxyz" + "ABCDEFGHIJKLMNOPQRSTUVWXYZ" + "0123456789",
+	}
+
+	randomSessionName, err := randomStringConfig.New()
+	if err != nil {
+		return "", fmt.Errorf("can not generate session name: %w", err)
+	}
+	return randomSessionName, nil
+}
+
+// partition returns the partition of the caller, or of region when the
+// caller is not known.
+func partition(region, callerArn string) string {
+	if p, err := identity.Parse(callerArn); err == nil {
+		return p.Partition
+	}
+	return identity.PartitionOf(region)
+}
+
+// isRoleSession reports whether the caller ARN belongs to an assumed role, in
+// which case assuming another role counts as role chaining.
+func isRoleSession(callerArn string) bool {
+	return strings.Contains(callerArn, ":assumed-role/")
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/michalschott/aws-login/pkg/login"
+)
+
+func TestRoleChain(t *testing.T) {
+	tests := []struct {
+		roles        string
+		sessionNames string
+		duration     durations
+		partition    string
+		want         []login.Role
+		wantErr      bool
+	}{
+		{
+			roles:        "admin",
+			sessionNames: "alice",
+			duration:     durations{3600},
+			want: []login.Role{
+				{Arn: "arn:aws:iam::111111111111:role/admin", SessionName: "alice", Duration: 3600},
+			},
+		},
+		{
+			roles:        "bastion, 222222222222:workload,arn:aws-cn:iam::333333333333:role/path/x",
+			sessionNames: "a,,c",
+			duration:     durations{7200, 900},
+			want: []login.Role{
+				{Arn: "arn:aws:iam::111111111111:role/bastion", SessionName: "a", Duration: 7200},
+				{Arn: "arn:aws:iam::222222222222:role/workload", Duration: 900},
+				{Arn: "arn:aws-cn:iam::333333333333:role/path/x", SessionName: "c", Duration: 900},
+			},
+		},
+		{
+			roles:        "admin,222222222222:workload",
+			sessionNames: "alice,bob",
+			duration:     durations{3600},
+			partition:    "aws-us-gov",
+			want: []login.Role{
+				{Arn: "arn:aws-us-gov:iam::111111111111:role/admin", SessionName: "alice", Duration: 3600},
+				{Arn: "arn:aws-us-gov:iam::222222222222:role/workload", SessionName: "bob", Duration: 3600},
+			},
+		},
+		{
+			roles:    "a,,b",
+			duration: durations{3600},
+			wantErr:  true,
+		},
+		{
+			roles:    "a",
+			duration: durations{2147483648},
+			wantErr:  true,
+		},
+	}
+
+	for _, test := range tests {
+		partition := test.partition
+		if partition == "" {
+			partition = "aws"
+		}
+		got, err := roleChain(test.roles, test.sessionNames, test.duration, partition, "111111111111")
+		if (test.wantErr && err == nil) || !test.wantErr && err != nil {
+			t.Errorf("err is wrong, roles=%v, wantErr=%v, err=%v", test.roles, test.wantErr, err)
+			continue
+		}
+
+		for i := range got {
+			// generated session names are random
+			if test.want[i].SessionName == "" {
+				if len(got[i].SessionName) != 16 {
+					t.Errorf("roles=%v: generated session name %q", test.roles, got[i].SessionName)
+				}
+				got[i].SessionName = ""
+			}
+		}
+		if !test.wantErr && !reflect.DeepEqual(got, test.want) {
+			t.Errorf("roles=%v: got %+v, expected %+v", test.roles, got, test.want)
+		}
+	}
+}
+
+func TestDurationsSet(t *testing.T) {
+	var d durations
+	if err := d.Set("3600, 900"); err != nil {
+		t.Fatal(err)
+	}
+	if d.String() != "3600,900" || d.hop(0) != 3600 || d.hop(5) != 900 {
+		t.Errorf("got %v", d)
+	}
+
+	tests := []struct {
+		value     string
+		wantClass *errorClass
+	}{
+		{value: "1h"},
+		{value: "0", wantClass: errUsage},
+		{value: "-900", wantClass: errUsage},
+		{value: "3600,0", wantClass: errUsage},
+	}
+
+	for _, tt := range tests {
+		err := d.Set(tt.value)
+		if err == nil {
+			t.Errorf("value=%q: expected error", tt.value)
+			continue
+		}
+		if tt.wantClass != nil && classify(err) != tt.wantClass {
+			t.Errorf("value=%q: class is %v, want %v", tt.value, classify(err), tt.wantClass)
+		}
+	}
+	if d.String() != "3600,900" {
+		t.Errorf("rejected values changed the durations to %v", d)
+	}
+}
+
+func TestTagsSet(t *testing.T) {
+	var tg tags
+	if err := tg.Set("team=platform, env = dev"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tg.Set("empty="); err != nil {
+		t.Fatal(err)
+	}
+	if tg.String() != "team=platform,env=dev,empty=" || len(tg.keys()) != 3 {
+		t.Errorf("got %v", tg.String())
+	}
+	if err := tg.Set("team"); err == nil {
+		t.Error("expected error for a tag without value")
+	}
+}
+
+func TestSessionSettings(t *testing.T) {
+	admin := login.Role{Arn: "arn:aws:iam::111111111111:role/admin"}
+	workload := login.Role{Arn: "arn:aws:iam::222222222222:role/workload"}
+
+	tests := []struct {
+		chain          []login.Role
+		tags           tags
+		transitiveTags tags
+		sourceIdentity string
+		externalID     string
+		want           []login.Role
+		wantErr        bool
+	}{
+		{want: nil},
+		{tags: tags{{Key: "team", Value: "platform"}}, wantErr: true},
+		{
+			chain:          []login.Role{admin, workload},
+			tags:           tags{{Key: "team", Value: "platform"}},
+			transitiveTags: tags{{Key: "project", Value: "login"}},
+			sourceIdentity: "alice",
+			externalID:     "secret",
+			want: []login.Role{
+				{Arn: admin.Arn, Tags: []login.Tag{{Key: "project", Value: "login"}}, TransitiveTagKeys: []string{"project"}, SourceIdentity: "alice"},
+				{Arn: workload.Arn, Tags: []login.Tag{{Key: "team", Value: "platform"}}, ExternalID: "secret"},
+			},
+		},
+		{chain: []login.Role{admin}, tags: tags{{Key: "aws:team", Value: "x"}}, wantErr: true},
+		{chain: []login.Role{admin}, tags: tags{{Key: "team", Value: "a"}, {Key: "Team", Value: "b"}}, wantErr: true},
+		{chain: []login.Role{admin}, tags: tags{{Key: "team", Value: "a;b"}}, wantErr: true},
+		{chain: []login.Role{admin}, sourceIdentity: "a", wantErr: true},
+		{chain: []login.Role{admin}, externalID: "no spaces", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		chain := append([]login.Role(nil), tt.chain...)
+		err := sessionSettings(chain, tt.tags, tt.transitiveTags, tt.sourceIdentity, tt.externalID)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("err is wrong, value=%+v, wantErr=%v, err=%v", tt.tags, tt.wantErr, err)
+			continue
+		}
+		if err == nil && !reflect.DeepEqual(chain, tt.want) {
+			t.Errorf("got %+v, expected %+v", chain, tt.want)
+		}
+		if err != nil && classify(err) != errUsage {
+			t.Errorf("%v is not a usage error", err)
+		}
+	}
+}
+
+func TestCacheRoles(t *testing.T) {
+	roles := cacheRoles([]login.Role{{Arn: "a", Duration: 3600}, {Arn: "b", Duration: 900}})
+	if len(roles) != 2 || roles[0].Arn != "a" || roles[1].Duration != 900 || roles[0].Settings != "" || roles[1].Settings != "" {
+		t.Errorf("got %+v for a chain without settings", roles)
+	}
+
+	// chains ending at the same role differ by the settings of every hop
+	a := cacheRoles([]login.Role{{Arn: "a", Tags: []login.Tag{{Key: "team", Value: "x"}}}, {Arn: "b"}})
+	b := cacheRoles([]login.Role{{Arn: "a", Tags: []login.Tag{{Key: "team", Value: "y"}}}, {Arn: "b"}})
+	if a[0].Settings == "" || a[0] == b[0] {
+		t.Errorf("settings %q and %q should differ", a[0].Settings, b[0].Settings)
+	}
+}
+
+func TestCallerName(t *testing.T) {
+	tests := []struct {
+		arn     string
+		want    string
+		wantErr bool
+	}{
+		{arn: "arn:aws:iam::111111111111:user/dev/alice", want: "alice"},
+		{arn: "arn:aws:sts::111111111111:assumed-role/admin/bob", want: "bob"},
+		{arn: "arn:aws:iam::111111111111:root", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := callerName(tt.arn)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("err is wrong, value=%v, wantErr=%v, err=%v", tt.arn, tt.wantErr, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %q, expected %q", tt.arn, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+type command struct {
+	name    string
+	usage   string
+	summary string
+	// flags registers the command flags, run is called with the remaining
+	// arguments once they are parsed
+	flags func(flags *flag.FlagSet) func(args []string) error
+}
+
+var commands []*command
+
+func init() {
+	commands = []*command{
+		{
+			name:    "session",
+			usage:   "session [flags]",
+			summary: "Get a session token, optionally authenticated with MFA",
+			flags: func(flags *flag.FlagSet) func([]string) error {
+				o := &options{}
+				o.registerSession(flags)
+				o.registerAgent(flags)
+				o.registerOutput(flags)
+				return noArgs(o.run)
+			},
+		},
+		{
+			name:    "assume",
+			usage:   "assume -role ROLE|-profile PROFILE [flags]",
+			summary: "Assume a role, optionally authenticated with MFA",
+			flags: func(flags *flag.FlagSet) func([]string) error {
+				o := &options{}
+				o.registerSession(flags)
+				o.registerRole(flags)
+				o.registerAgent(flags)
+				o.registerOutput(flags)
+				return noArgs(func() error {
+					if o.Role == "" && o.Profile == "" {
+						return withClass(errUsage, errors.New("-role or -profile is required"))
+					}
+					return o.run()
+				})
+			},
+		},
+		{
+			name:    "federate",
+			usage:   "federate -policy-file FILE|-policy-arn ARN|-policy-preset PRESET [-name NAME] [flags]",
+			summary: "Get credentials of a federated user limited by a session policy",
+			flags:   federateCommand,
+		},
+		{
+			name:    "web-identity",
+			usage:   "web-identity -role ARN [-token-file FILE|-token-env VAR|-token-command CMD] [flags]",
+			summary: "Assume a role with an OIDC token of a CI job, without base credentials",
+			flags:   webIdentityCommand,
+		},
+		{
+			name:    "sso",
+			usage:   "sso -profile PROFILE | -start-url URL -sso-region REGION [-account ACCOUNT] [-role ROLE] [flags]",
+			summary: "Get role credentials from AWS IAM Identity Center (SSO)",
+			flags:   ssoCommand,
+		},
+		{
+			name:    "pick",
+			usage:   "pick [-filter QUERY] [-list] [flags]",
+			summary: "Pick a role from the shared config file and IAM Identity Center and get its credentials",
+			flags:   pickCommand,
+		},
+		{
+			name:    "exec",
+			usage:   "exec [flags] -- COMMAND [ARGS...]",
+			summary: "Run a command with temporary credentials set only in its environment",
+			flags:   execCommand,
+		},
+		{
+			name:    "console",
+			usage:   "console -role ROLE|-profile PROFILE [-destination PATH] [-open] [flags]",
+			summary: "Sign in to the AWS web console with an assumed role",
+			flags:   consoleCommand,
+		},
+		{
+			name:    "serve-imds",
+			usage:   "serve-imds [-listen ADDRESS] [flags]",
+			summary: "Serve refreshed credentials through an IMDSv2 compatible metadata endpoint",
+			flags:   imdsCommand,
+		},
+		{
+			name:    "serve-ecs",
+			usage:   "serve-ecs [-listen ADDRESS] [flags]",
+			summary: "Serve refreshed credentials through an ECS container credentials endpoint",
+			flags:   ecsCommand,
+		},
+		{
+			name:    "agent",
+			usage:   "agent [start|list|revoke] [-socket PATH] [flags]",
+			summary: "Keep sessions refreshed in the background and hand them out over a Unix socket",
+			flags:   agentCommand,
+		},
+		{
+			name:    "whoami",
+			usage:   "whoami [-profile PROFILE] [-o text|json]",
+			summary: "Show the identity of the credentials currently in use",
+			flags:   whoamiCommand,
+		},
+		{
+			name:    "mfa",
+			usage:   "mfa add|remove|code [-serial SERIAL] [-store STORE] [URI]",
+			summary: "Manage virtual MFA seeds used to generate MFA codes",
+			flags:   mfaCommand,
+		},
+		{
+			name:    "cache",
+			usage:   "cache list|clear [-role ROLE]",
+			summary: "List or clear cached sessions",
+			flags:   cacheCommand,
+		},
+		{
+			name:    "version",
+			usage:   "version",
+			summary: "Print version information",
+			flags: func(flags *flag.FlagSet) func([]string) error {
+				return noArgs(func() error {
+					fmt.Printf("aws-login %s, commit %s, built on %s\n", version, commit, date)
+					return nil
+				})
+			},
+		},
+		{
+			name:    "help",
+			usage:   "help [COMMAND]",
+			summary: "Show help for aws-login or one of its commands",
+			flags: func(flags *flag.FlagSet) func([]string) error {
+				return help
+			},
+		},
+	}
+}
+
+// legacy is the default action used when no command is given. It keeps the
+// original flat set of flags working: a role is assumed when -role is set,
+// otherwise a session token is requested.
+var legacy = &command{
+	name:  "aws-login",
+	usage: "[flags]",
+	flags: func(flags *flag.FlagSet) func([]string) error {
+		o := &options{}
+		o.registerSession(flags)
+		o.registerRole(flags)
+		o.registerAgent(flags)
+		o.registerOutput(flags)
+		return noArgs(o.run)
+	},
+}
+
+func noArgs(run func() error) func([]string) error {
+	return func(args []string) error {
+		if len(args) > 0 {
+			return withClass(errUsage, fmt.Errorf("unexpected arguments: %s", strings.Join(args, " ")))
+		}
+		return run()
+	}
+}
+
+func findCommand(name string) *command {
+	for _, c := range commands {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// flagSet returns the flags of the command together with its action.
+func (c *command) flagSet() (*flag.FlagSet, func([]string) error) {
+	flags := flag.NewFlagSet(c.name, flag.ExitOnError)
+	run := c.flags(flags)
+	flags.Usage = func() { c.help(flags.Output(), flags) }
+	return flags, run
+}
+
+func (c *command) help(w io.Writer, flags *flag.FlagSet) {
+	if c == legacy {
+		usage(w)
+	} else {
+		_, _ = fmt.Fprintf(w, "Usage: aws-login %s\n\n%s.\n", c.usage, c.summary)
+	}
+
+	hasFlags := false
+	flags.VisitAll(func(*flag.Flag) { hasFlags = true })
+	if hasFlags {
+		_, _ = fmt.Fprintln(w, "\nFlags:")
+		flags.PrintDefaults()
+	}
+}
+
+func usage(w io.Writer) {
+	_, _ = fmt.Fprintln(w, "Usage: aws-login [COMMAND] [flags]")
+	_, _ = fmt.Fprintln(w, "\nCommands:")
+	for _, c := range commands {
+		_, _ = fmt.Fprintf(w, "  %-12s %s\n", c.name, c.summary)
+	}
+	_, _ = fmt.Fprintln(w, "\nWithout a command aws-login assumes -role when it is set and gets a session token otherwise.")
+	_, _ = fmt.Fprintln(w, "Run 'aws-login help COMMAND' for the flags of a command.")
+}
+
+func help(args []string) error {
+	if len(args) == 0 {
+		usage(os.Stdout)
+		return nil
+	}
+
+	c := findCommand(args[0])
+	if c == nil {
+		return withClass(errUsage, fmt.Errorf("unknown command %q", args[0]))
+	}
+
+	flags, _ := c.flagSet()
+	flags.SetOutput(os.Stdout)
+	c.help(os.Stdout, flags)
+	return nil
+}
+
+// run dispatches the command line to a command, falling back to the legacy
+// flags when the first argument is not a command name.
+func run(args []string) error {
+	c := legacy
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		c = findCommand(args[0])
+		if c == nil {
+			usage(os.Stderr)
+			return withClass(errUsage, fmt.Errorf("unknown command %q", args[0]))
+		}
+		args = args[1:]
+	}
+
+	flags, action := c.flagSet()
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	return action(flags.Args())
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os/exec"
+	"runtime"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+
+	"github.com/michalschott/aws-login/pkg/console"
+	"github.com/michalschott/aws-login/pkg/login"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func consoleCommand(flags *flag.FlagSet) func([]string) error {
+	o := &options{}
+	o.registerSession(flags)
+	o.registerRole(flags)
+	o.registerAgent(flags)
+	Destination := flags.String("destination", "", "Console page to open, as a path such as /ec2/home or a full URL")
+	Issuer := flags.String("issuer", "", "URL the console sends you to once the session expires")
+	Open := flags.Bool("open", false, "Open the sign-in URL in the browser instead of printing it")
+	FederationURL := flags.String("federation-url", "", "Federation endpoint, defaults to the one of the region's partition")
+
+	return noArgs(func() error {
+		// the federation endpoint does not accept GetSessionToken sessions
+		if o.Role == "" && o.Profile == "" {
+			return withClass(errUsage, errors.New("-role or -profile is required"))
+		}
+		if err := o.prepare(); err != nil {
+			return err
+		}
+
+		ctx := context.Background()
+		cfg, err := o.config(ctx)
+		if err != nil {
+			return err
+		}
+		credentials, err := o.obtain(ctx, cfg)
+		if err != nil {
+			return err
+		}
+
+		partition := o.consolePartition(ctx, cfg, credentials)
+		endpoint := *FederationURL
+		if endpoint == "" {
+			endpoint = partition.Federation
+		}
+		log.Debugf("Requesting a sign-in token from %s for partition %s.", endpoint, partition.Name)
+
+		// the SDK client honours -proxy and -ca-bundle
+		token, err := console.SigninToken(ctx, cfg.HTTPClient, endpoint, credentials.Format())
+		if err != nil {
+			return err
+		}
+
+		url := console.LoginURL(endpoint, token, partition.Destination(*Destination), *Issuer)
+		if *Open {
+			return openBrowser(url)
+		}
+		fmt.Println(url)
+		return nil
+	})
+}
+
+// consolePartition returns the partition of the session, taken from its ARN
+// like the partition of the roles, or from the region when STS can not tell.
+func (o *options) consolePartition(ctx context.Context, cfg aws.Config, credentials *credentials) console.Partition {
+	c := credentials.Format()
+	client := login.ClientFor(cfg, o.stsOptions)(login.Credentials{AccessKeyID: c.AccessKeyID, SecretAccessKey: c.SecretAccessKey, SessionToken: c.SessionToken})
+	callerArn := ""
+	if caller, err := login.CallerIdentity(ctx, client); err == nil {
+		callerArn = caller.Arn
+	} else {
+		log.Debugf("Can not get the identity of the session, taking the partition of the region: %v", err)
+	}
+	return console.Lookup(partition(cfg.Region, callerArn))
+}
+
+// openBrowser opens url with the default browser of the desktop.
+func openBrowser(url string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", url) // #nosec G204 -- the URL is passed as a single argument
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url) // #nosec G204 -- the URL is passed as a single argument
+	default:
+		cmd = exec.Command("xdg-open", url) // #nosec G204 -- the URL is passed as a single argument
+	}
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("can not open the browser, use the URL printed without -open: %w", err)
+	}
+	return cmd.Process.Release()
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestConsolePartition(t *testing.T) {
+	isolateConfig(t)
+	tests := []struct {
+		arn    string
+		status int
+		want   string
+	}{
+		{arn: "arn:aws-us-gov:sts::111111111111:assumed-role/Admin/alice", status: http.StatusOK, want: "aws-us-gov"},
+		{arn: "arn:aws-cn:sts::111111111111:assumed-role/Admin/alice", status: http.StatusOK, want: "aws-cn"},
+		// the region decides when STS does not answer
+		{status: http.StatusForbidden, want: "aws"},
+	}
+
+	for _, tt := range tests {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/xml")
+			w.WriteHeader(tt.status)
+			if tt.status != http.StatusOK {
+				_, _ = w.Write([]byte(`<ErrorResponse><Error><Type>Sender</Type><Code>AccessDenied</Code><Message>denied</Message></Error></ErrorResponse>`))
+				return
+			}
+			_, _ = fmt.Fprintf(w, `<GetCallerIdentityResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/"><GetCallerIdentityResult>`+
+				`<Arn>%s</Arn><UserId>AROAFAKE:alice</UserId><Account>111111111111</Account>`+
+				`</GetCallerIdentityResult></GetCallerIdentityResponse>`, tt.arn)
+		}))
+
+		o := &options{Region: "eu-west-1", network: network{EndpointURL: srv.URL}}
+		cfg, err := o.config(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		credentials := new(credentials)
+		credentials.New("ASIAFAKE", "secret", "token", time.Now().Add(time.Hour))
+		if got := o.consolePartition(context.Background(), cfg, credentials); got.Name != tt.want {
+			t.Errorf("%s: got partition %s, expected %s", tt.arn, got.Name, tt.want)
+		}
+		srv.Close()
+	}
+}
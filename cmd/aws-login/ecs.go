@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+
+	"github.com/michalschott/aws-login/pkg/ecs"
+	"github.com/michalschott/aws-login/pkg/format"
+	"github.com/michalschott/aws-login/pkg/random"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func ecsCommand(flags *flag.FlagSet) func([]string) error {
+	o := &options{}
+	o.registerSession(flags)
+	o.registerRole(flags)
+	Listen := flags.String("listen", "127.0.0.1:9912", "Address to serve the container credentials endpoint on")
+	Advertise := flags.String("advertise", "", "`Host` containers reach the endpoint at, the host of -listen when not set")
+
+	return noArgs(func() error {
+		if err := o.prepare(); err != nil {
+			return err
+		}
+		if *Advertise != "" && !containerHost(*Advertise) {
+			return withClass(errUsage, errors.New("-advertise has to be a loopback address, 169.254.170.2, 169.254.170.23, fd00:ec2::23 or a host name resolving to one of them, the SDKs refuse other plain HTTP endpoints"))
+		}
+
+		randomStringConfig := random.RandomStringConfig{
+			Length:  32,
+			Charset: "abcdefghijklmnopqrstuvwxyz" + "ABCDEFGHIJKLMNOPQRSTUVWXYZ" + "0123456789",
+		}
+		token, err := randomStringConfig.New()
+		if err != nil {
+			return fmt.Errorf("can not generate authorization token: %w", err)
+		}
+
+		ctx := context.Background()
+		cfg, err := o.config(ctx)
+		if err != nil {
+			return err
+		}
+		s := &session{o: o, cfg: cfg}
+
+		// log in before serving, so MFA is asked for right away
+		if _, err := s.get(ctx); err != nil {
+			return err
+		}
+
+		handler := &ecs.Server{Token: token, Credentials: s.get}
+		return serve(*Listen, handler, func(addr string) []format.Variable {
+			return containerEnv(containerURI(addr, *Advertise), token, o.region)
+		})
+	})
+}
+
+// containerEnv returns the variables pointing containers at the endpoint
+// uri. Only a configured region is passed on, so containers keep their own
+// otherwise.
+func containerEnv(uri, token, region string) []format.Variable {
+	env := []format.Variable{
+		{Name: "AWS_CONTAINER_CREDENTIALS_FULL_URI", Value: uri},
+		{Name: "AWS_CONTAINER_AUTHORIZATION_TOKEN", Value: token},
+	}
+	if region != "" {
+		env = append(env, format.Variable{Name: "AWS_REGION", Value: region})
+	}
+	return env
+}
+
+// containerHost tells whether the SDKs accept a plain HTTP endpoint on host:
+// loopback addresses and the addresses of the ECS and EKS credential
+// endpoints. Host names are resolved in the container and can not be checked.
+func containerHost(host string) bool {
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return true
+	}
+	return ip.IsLoopback() || ip.Equal(net.ParseIP("169.254.170.2")) ||
+		ip.Equal(net.ParseIP("169.254.170.23")) || ip.Equal(net.ParseIP("fd00:ec2::23"))
+}
+
+// containerURI returns the URI of the endpoint listening on addr, at the
+// advertised host if one is given.
+func containerURI(addr, advertise string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "http://" + addr + "/"
+	}
+	if advertise != "" {
+		host = advertise
+	} else if !containerHost(host) {
+		log.Warnf("Containers can not use the endpoint at %s, set -advertise.", host)
+	}
+	return "http://" + net.JoinHostPort(host, port) + "/"
+}
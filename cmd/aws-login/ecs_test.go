@@ -0,0 +1,83 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/michalschott/aws-login/pkg/format"
+)
+
+func TestContainerURI(t *testing.T) {
+	tests := []struct {
+		addr      string
+		advertise string
+		want      string
+	}{
+		{addr: "127.0.0.1:9912", want: "http://127.0.0.1:9912/"},
+		{addr: "[::1]:9912", want: "http://[::1]:9912/"},
+		{addr: "169.254.170.2:9912", want: "http://169.254.170.2:9912/"},
+		{addr: "0.0.0.0:9912", advertise: "169.254.170.2", want: "http://169.254.170.2:9912/"},
+		{addr: "[::]:9912", advertise: "fd00:ec2::23", want: "http://[fd00:ec2::23]:9912/"},
+		{addr: "0.0.0.0:9912", advertise: "credentials.local", want: "http://credentials.local:9912/"},
+	}
+
+	for _, tt := range tests {
+		if got := containerURI(tt.addr, tt.advertise); got != tt.want {
+			t.Errorf("%s advertised as %q: got %s, expected %s", tt.addr, tt.advertise, got, tt.want)
+		}
+	}
+}
+
+func TestContainerHost(t *testing.T) {
+	tests := []struct {
+		value string
+		want  bool
+	}{
+		{value: "127.0.0.1", want: true},
+		{value: "::1", want: true},
+		{value: "169.254.170.2", want: true},
+		{value: "169.254.170.23", want: true},
+		{value: "fd00:ec2::23", want: true},
+		{value: "localhost", want: true},
+		{value: "0.0.0.0", want: false},
+		{value: "172.17.0.1", want: false},
+		{value: "192.168.65.254", want: false},
+	}
+
+	for _, tt := range tests {
+		if got := containerHost(tt.value); got != tt.want {
+			t.Errorf("%s: got %v, expected %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestContainerEnv(t *testing.T) {
+	uri := "http://127.0.0.1:9912/"
+	tests := []struct {
+		region string
+		want   []format.Variable
+	}{
+		{region: "", want: []format.Variable{
+			{Name: "AWS_CONTAINER_CREDENTIALS_FULL_URI", Value: uri},
+			{Name: "AWS_CONTAINER_AUTHORIZATION_TOKEN", Value: "token"},
+		}},
+		{region: "eu-west-1", want: []format.Variable{
+			{Name: "AWS_CONTAINER_CREDENTIALS_FULL_URI", Value: uri},
+			{Name: "AWS_CONTAINER_AUTHORIZATION_TOKEN", Value: "token"},
+			{Name: "AWS_REGION", Value: "eu-west-1"},
+		}},
+	}
+
+	for _, tt := range tests {
+		got := containerEnv(uri, "token", tt.region)
+		if len(got) != len(tt.want) {
+			t.Errorf("region %q: got %v, expected %v", tt.region, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("region %q: got %v, expected %v", tt.region, got, tt.want)
+				break
+			}
+		}
+	}
+}
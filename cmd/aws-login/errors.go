@@ -0,0 +1,185 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/smithy-go"
+
+	"github.com/michalschott/aws-login/pkg/agent"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// exitFailure is the exit status of errors that do not belong to a class.
+const exitFailure = 1
+
+// errorClass groups failures sharing an exit status and a remediation hint.
+type errorClass struct {
+	name string
+	code int
+	hint string
+}
+
+var (
+	errUsage = &errorClass{
+		name: "usage",
+		code: 2,
+		hint: "Run 'aws-login help' for the commands and their flags.",
+	}
+	errConfig = &errorClass{
+		name: "config",
+		code: 3,
+		hint: "Check the shared config file and the -profile and -region flags.",
+	}
+	errNoCredentials = &errorClass{
+		name: "no-credentials",
+		code: 4,
+		hint: "Configure access keys for AWS_PROFILE with 'aws configure' or set AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY. web-identity needs an OIDC token instead.",
+	}
+	errExpired = &errorClass{
+		name: "expired-token",
+		code: 5,
+		hint: "The base credentials have expired. Unset AWS_SESSION_TOKEN or get new ones, with 'aws-login sso' for SSO profiles.",
+	}
+	errMfa = &errorClass{
+		name: "mfa",
+		code: 6,
+		hint: "Check the MFA code, the clock of the device and that -mfa-serial names an MFA device of this user.",
+	}
+	errAccessDenied = &errorClass{
+		name: "access-denied",
+		code: 7,
+		hint: "Check that the policies of the caller allow the call that was denied.",
+	}
+	errThrottled = &errorClass{
+		name: "throttled",
+		code: 8,
+		hint: "AWS is throttling requests. Wait a moment and retry, and let sessions be reused from the credential cache.",
+	}
+)
+
+var errorClasses = []*errorClass{errUsage, errConfig, errNoCredentials, errExpired, errMfa, errAccessDenied, errThrottled}
+
+// apiErrorClasses maps error codes of AWS APIs to classes.
+var apiErrorClasses = map[string]*errorClass{
+	"ExpiredToken":                errExpired,
+	"ExpiredTokenException":       errExpired,
+	"RequestExpired":              errExpired,
+	"UnauthorizedException":       errExpired,
+	"InvalidClientTokenId":        errNoCredentials,
+	"UnrecognizedClientException": errNoCredentials,
+	"SignatureDoesNotMatch":       errNoCredentials,
+	"IncompleteSignature":         errNoCredentials,
+	"MissingAuthenticationToken":  errNoCredentials,
+	"InvalidIdentityToken":        errNoCredentials,
+	"AccessDenied":                errAccessDenied,
+	"AccessDeniedException":       errAccessDenied,
+	"UnauthorizedOperation":       errAccessDenied,
+	"IDPRejectedClaim":            errAccessDenied,
+	"Throttling":                  errThrottled,
+	"ThrottlingException":         errThrottled,
+	"TooManyRequestsException":    errThrottled,
+	"RequestLimitExceeded":        errThrottled,
+	"RegionDisabledException":     errConfig,
+	"MalformedPolicyDocument":     errUsage,
+	"PackedPolicyTooLarge":        errUsage,
+}
+
+// apiErrorHints replace the hint of the class for error codes of AWS APIs
+// with a more specific remediation.
+var apiErrorHints = map[string]string{
+	"MalformedPolicyDocument": "STS rejected the session policy. Check the document of -policy-file against the IAM policy grammar.",
+	"PackedPolicyTooLarge":    "STS packs the session policy, the -policy-arn ARNs and the session tags together and found them too large. Shorten the policy, or pass fewer ARNs and tags.",
+}
+
+// accessDeniedHints replace the hint of errAccessDenied for the STS operation
+// that was denied.
+var accessDeniedHints = map[string]string{
+	"AssumeRole":                "Check that the trust policy of the role allows the caller and that the caller may call sts:AssumeRole.",
+	"AssumeRoleWithWebIdentity": "Check that the trust policy of the role allows the OIDC provider and the audience and subject of the token.",
+	"GetSessionToken":           "Check that the caller may call sts:GetSessionToken. GetSessionToken needs long term access keys of an IAM user.",
+	"GetFederationToken":        "Check that the caller may call sts:GetFederationToken. GetFederationToken needs long term access keys of an IAM user.",
+}
+
+// classifiedError attaches a class to an error.
+type classifiedError struct {
+	class *errorClass
+	err   error
+}
+
+func (e *classifiedError) Error() string { return e.err.Error() }
+func (e *classifiedError) Unwrap() error { return e.err }
+
+func withClass(class *errorClass, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &classifiedError{class: class, err: err}
+}
+
+// classify returns the class of err, or nil when it has none.
+func classify(err error) *errorClass {
+	var classified *classifiedError
+	if errors.As(err, &classified) {
+		return classified.class
+	}
+
+	// failures of the agent keep the class they had there
+	var agentErr *agent.Error
+	if errors.As(err, &agentErr) {
+		for _, class := range errorClasses {
+			if class.name == agentErr.Class {
+				return class
+			}
+		}
+		return nil
+	}
+
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		// STS rejects wrong MFA codes with AccessDenied
+		if mfaRejected(apiErr) {
+			return errMfa
+		}
+		return apiErrorClasses[apiErr.ErrorCode()]
+	}
+
+	var notExist config.SharedConfigProfileNotExistError
+	var load config.SharedConfigLoadError
+	if errors.As(err, &notExist) || errors.As(err, &load) {
+		return errConfig
+	}
+	return nil
+}
+
+// hint returns the remediation for err of class, specific to the AWS error
+// code or the denied operation when there is one.
+func hint(err error, class *errorClass) string {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		if h, ok := apiErrorHints[apiErr.ErrorCode()]; ok {
+			return h
+		}
+	}
+	var opErr *smithy.OperationError
+	if class == errAccessDenied && errors.As(err, &opErr) {
+		if h, ok := accessDeniedHints[opErr.Operation()]; ok {
+			return h
+		}
+	}
+	return class.hint
+}
+
+// fail logs err together with the hint of its class and returns the exit
+// status aws-login ends with.
+func fail(err error) int {
+	class := classify(err)
+	if class == nil {
+		log.Error(err)
+		return exitFailure
+	}
+
+	log.WithFields(log.Fields{"class": class.name, "hint": hint(err, class)}).Error(err)
+	return class.code
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/smithy-go"
+
+	"github.com/michalschott/aws-login/pkg/agent"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		err  error
+		want *errorClass
+	}{
+		{err: errors.New("something else"), want: nil},
+		{err: withClass(errUsage, errors.New("unexpected arguments")), want: errUsage},
+		{err: fmt.Errorf("assuming x: %w", withClass(errMfa, errors.New("invalid MFA code"))), want: errMfa},
+		{err: &smithy.GenericAPIError{Code: "ExpiredToken", Message: "The security token included in the request is expired"}, want: errExpired},
+		{err: &smithy.GenericAPIError{Code: "AccessDenied", Message: "MultiFactorAuthentication failed with invalid MFA one time pass code."}, want: errMfa},
+		{err: fmt.Errorf("assuming x: %w", &smithy.GenericAPIError{Code: "AccessDenied", Message: "not authorized to perform: sts:AssumeRole"}), want: errAccessDenied},
+		{err: &smithy.GenericAPIError{Code: "Throttling", Message: "Rate exceeded"}, want: errThrottled},
+		{err: &smithy.GenericAPIError{Code: "InvalidClientTokenId", Message: "The security token included in the request is invalid."}, want: errNoCredentials},
+		{err: &smithy.GenericAPIError{Code: "ValidationError", Message: "1 validation error"}, want: nil},
+		{err: fmt.Errorf("assuming x: %w", &smithy.GenericAPIError{Code: "PackedPolicyTooLarge", Message: "Packed size of consumed data exceeds 100% of limit."}), want: errUsage},
+		{err: config.SharedConfigProfileNotExistError{Profile: "missing"}, want: errConfig},
+		{err: &agent.Error{Message: "expired", Class: "expired-token"}, want: errExpired},
+		{err: &agent.Error{Message: "failed"}, want: nil},
+	}
+
+	for _, tt := range tests {
+		if got := classify(tt.err); got != tt.want {
+			t.Errorf("class of %v is %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestHint(t *testing.T) {
+	packed := fmt.Errorf("assuming x: %w", &smithy.GenericAPIError{Code: "PackedPolicyTooLarge"})
+	if got := hint(packed, classify(packed)); got != apiErrorHints["PackedPolicyTooLarge"] {
+		t.Errorf("got hint %q", got)
+	}
+
+	denied := &smithy.GenericAPIError{Code: "AccessDenied"}
+	if got := hint(denied, classify(denied)); got != errAccessDenied.hint {
+		t.Errorf("got hint %q", got)
+	}
+
+	for _, operation := range []string{"AssumeRole", "AssumeRoleWithWebIdentity", "GetSessionToken", "GetFederationToken"} {
+		err := fmt.Errorf("logging in: %w", &smithy.OperationError{ServiceID: "STS", OperationName: operation, Err: denied})
+		if got := hint(err, classify(err)); got != accessDeniedHints[operation] {
+			t.Errorf("%s: got hint %q", operation, got)
+		}
+	}
+
+	// the hint of the operation is only given for access denied
+	expired := &smithy.OperationError{ServiceID: "STS", OperationName: "AssumeRole", Err: &smithy.GenericAPIError{Code: "ExpiredToken"}}
+	if got := hint(expired, classify(expired)); got != errExpired.hint {
+		t.Errorf("got hint %q", got)
+	}
+}
+
+func TestExitCodes(t *testing.T) {
+	seen := map[int]string{exitFailure: "failure"}
+	for _, c := range errorClasses {
+		if other, ok := seen[c.code]; ok {
+			t.Errorf("%s uses exit code %d of %s", c.name, c.code, other)
+		}
+		seen[c.code] = c.name
+		if c.hint == "" {
+			t.Errorf("%s has no hint", c.name)
+		}
+	}
+}
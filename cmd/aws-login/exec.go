@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/michalschott/aws-login/pkg/format"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// forwardedSignals are passed on to the child process instead of terminating
+// aws-login.
+var forwardedSignals = []os.Signal{os.Interrupt, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGQUIT}
+
+func execCommand(flags *flag.FlagSet) func([]string) error {
+	o := &options{}
+	o.registerSession(flags)
+	o.registerRole(flags)
+	o.registerAgent(flags)
+
+	return func(args []string) error {
+		if len(args) == 0 {
+			return withClass(errUsage, errors.New("expected a command to run after --"))
+		}
+
+		if err := o.prepare(); err != nil {
+			return err
+		}
+
+		ctx := context.Background()
+		// the fallback region of cfg is not exported, only o.region
+		cfg, err := o.config(ctx)
+		if err != nil {
+			return err
+		}
+		credentials, err := o.obtain(ctx, cfg)
+		if err != nil {
+			return err
+		}
+
+		env := childEnv(os.Environ(), credentials, o.region)
+		os.Exit(runChild(args, env))
+		return nil
+	}
+}
+
+// childEnv replaces all credential related variables of the current
+// environment with the temporary credentials.
+func childEnv(environ []string, credentials *credentials, region string) []string {
+	drop := map[string]bool{
+		"AWS_PROFILE":               true,
+		"AWS_DEFAULT_PROFILE":       true,
+		"AWS_SECURITY_TOKEN":        true,
+		"AWS_CREDENTIAL_EXPIRATION": true,
+	}
+	vars := format.Credentials{
+		AccessKeyID:     credentials.awsAccessKeyId,
+		SecretAccessKey: credentials.awsSecretAccessKey,
+		SessionToken:    credentials.awsSessionToken,
+		Region:          region,
+	}.Env()
+	for _, v := range vars {
+		drop[v.Name] = true
+	}
+	if !credentials.expiration.IsZero() {
+		vars = append(vars, format.Variable{Name: "AWS_CREDENTIAL_EXPIRATION", Value: credentials.expiration.UTC().Format(time.RFC3339)})
+	}
+
+	env := []string{}
+	for _, e := range environ {
+		name, _, _ := strings.Cut(e, "=")
+		if !drop[name] {
+			env = append(env, e)
+		}
+	}
+	for _, v := range vars {
+		env = append(env, v.Name+"="+v.Value)
+	}
+	return env
+}
+
+// runChild runs args with env, forwarding signals, and returns its exit status.
+func runChild(args []string, env []string) int {
+	cmd := exec.Command(args[0], args[1:]...) // #nosec G204 -- running the given command is the purpose of exec
+	cmd.Env = env
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, forwardedSignals...)
+	defer signal.Stop(signals)
+
+	if err := cmd.Start(); err != nil {
+		log.Error(err)
+		return 127
+	}
+
+	go func() {
+		for sig := range signals {
+			if err := cmd.Process.Signal(sig); err != nil {
+				log.Debug("Can not forward signal: ", err)
+			}
+		}
+	}()
+
+	err := cmd.Wait()
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		return 0
+	case errors.As(err, &exitErr):
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			return 128 + int(status.Signal())
+		}
+		return exitErr.ExitCode()
+	default:
+		log.Error(err)
+		return 1
+	}
+}
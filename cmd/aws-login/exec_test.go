@@ -0,0 +1,36 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChildEnv(t *testing.T) {
+	environ := []string{
+		"HOME=/home/alice",
+		"AWS_PROFILE=default",
+		"AWS_ACCESS_KEY_ID=AKIAOLD",
+		"AWS_REGION=us-east-1",
+		"AWS_DEFAULT_REGION=us-east-1",
+		"AWS_SECURITY_TOKEN=old",
+	}
+
+	c := new(credentials)
+	c.New("ASIA", "secret", "token", time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC))
+
+	got := strings.Join(childEnv(environ, c, "eu-west-1"), "\n")
+	expected := strings.Join([]string{
+		"HOME=/home/alice",
+		"AWS_ACCESS_KEY_ID=ASIA",
+		"AWS_SECRET_ACCESS_KEY=secret",
+		"AWS_SESSION_TOKEN=token",
+		"AWS_REGION=eu-west-1",
+		"AWS_DEFAULT_REGION=eu-west-1",
+		"AWS_CREDENTIAL_EXPIRATION=2030-01-02T03:04:05Z",
+	}, "\n")
+
+	if got != expected {
+		t.Errorf("got\n%s\nbut expected\n%s", got, expected)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+
+	"github.com/michalschott/aws-login/pkg/login"
+	"github.com/michalschott/aws-login/pkg/random"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func federateCommand(flags *flag.FlagSet) func([]string) error {
+	// GetFederationToken supports neither MFA nor the session cache, only
+	// the flags it uses are registered
+	o := &options{federate: true}
+	flags.BoolVar(&o.Debug, "debug", false, "Debug")
+	flags.StringVar(&o.FederatedName, "name", "", "Name of the federated user, the caller's user name when not set")
+	o.Duration = durations{3600}
+	flags.Var(&o.Duration, "duration", "Session duration in `seconds`")
+	flags.StringVar(&o.Region, "region", "", "AWS region, taken from the profile or AWS_REGION when not set")
+	flags.BoolVar(&o.NoUnset, "nounset", false, "Should current AWS* env variables be unset before assuming new creds. Used in chain-assume scenarios.")
+	o.registerNetwork(flags)
+	o.registerPolicy(flags)
+	o.registerOutput(flags)
+
+	return noArgs(o.run)
+}
+
+// federation gets credentials of a federated user limited to the session
+// policy. Sessions of federated users are not cached.
+func (o *options) federation(ctx context.Context, cfg aws.Config) (*credentials, error) {
+	if cfg.Credentials == nil {
+		return nil, withClass(errNoCredentials, fmt.Errorf("no base credentials found for profile %s", os.Getenv("AWS_PROFILE")))
+	}
+	stsSvc := sts.NewFromConfig(cfg, o.stsOptions)
+
+	callerArn := ""
+	name := o.FederatedName
+	if name == "" {
+		c, err := login.CallerIdentity(ctx, stsSvc)
+		if err != nil {
+			return nil, err
+		}
+		callerArn = c.Arn
+		if name, err = callerName(c.Arn); err != nil {
+			return nil, withClass(errUsage, fmt.Errorf("%w, set -name", err))
+		}
+	}
+
+	p, err := o.sessionPolicy(partition(cfg.Region, callerArn))
+	if err != nil {
+		return nil, err
+	}
+	if p.IsZero() {
+		return nil, withClass(errUsage, errors.New("-policy-file, -policy-arn or -policy-preset is required, federated users are allowed nothing without a policy"))
+	}
+	duration, err := random.IntToInt32(o.Duration.hop(0))
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debugf("Getting a federation token for %s with duration %d.", name, duration)
+	result, err := login.FederationToken(ctx, stsSvc, name, duration, p)
+	if err != nil {
+		return nil, err
+	}
+
+	credentials := new(credentials)
+	credentials.New(result.AccessKeyID, result.SecretAccessKey, result.SessionToken, result.Expiration)
+	credentials.region = o.region
+	return credentials, nil
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"flag"
+	"testing"
+)
+
+// federate only registers the flags GetFederationToken uses.
+func TestFederateFlags(t *testing.T) {
+	flags := flag.NewFlagSet("federate", flag.ContinueOnError)
+	federateCommand(flags)
+
+	for _, name := range []string{"name", "duration", "region", "policy-preset", "format", "endpoint-url"} {
+		if flags.Lookup(name) == nil {
+			t.Errorf("flag -%s is not registered", name)
+		}
+	}
+	for _, name := range []string{"mfa", "mfa-stdin", "mfa-serial", "totp", "totp-skew", "no-cache", "refresh-window", "role", "no-agent"} {
+		if flags.Lookup(name) != nil {
+			t.Errorf("flag -%s is registered", name)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"flag"
+
+	"github.com/michalschott/aws-login/pkg/format"
+	"github.com/michalschott/aws-login/pkg/imds"
+)
+
+func imdsCommand(flags *flag.FlagSet) func([]string) error {
+	o := &options{}
+	o.registerSession(flags)
+	o.registerRole(flags)
+	Listen := flags.String("listen", "127.0.0.1:9911", "Address to serve the metadata endpoints on")
+	RoleName := flags.String("role-name", "aws-login", "Name the credentials are listed under in iam/security-credentials/")
+
+	return noArgs(func() error {
+		if err := o.prepare(); err != nil {
+			return err
+		}
+
+		ctx := context.Background()
+		cfg, err := o.config(ctx)
+		if err != nil {
+			return err
+		}
+		s := &session{o: o, cfg: cfg}
+
+		// log in before serving, so MFA is asked for right away
+		if _, err := s.get(ctx); err != nil {
+			return err
+		}
+
+		handler := &imds.Server{Role: *RoleName, Region: cfg.Region, Credentials: s.get}
+		return serve(*Listen, handler, func(addr string) []format.Variable {
+			return []format.Variable{
+				{Name: "AWS_EC2_METADATA_SERVICE_ENDPOINT", Value: "http://" + addr},
+			}
+		})
+	})
+}
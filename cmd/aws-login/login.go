@@ -0,0 +1,591 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+
+	"github.com/michalschott/aws-login/pkg/agent"
+	"github.com/michalschott/aws-login/pkg/awsconfig"
+	"github.com/michalschott/aws-login/pkg/cache"
+	"github.com/michalschott/aws-login/pkg/format"
+	"github.com/michalschott/aws-login/pkg/identity"
+	"github.com/michalschott/aws-login/pkg/keyring"
+	"github.com/michalschott/aws-login/pkg/login"
+	"github.com/michalschott/aws-login/pkg/policy"
+	"github.com/michalschott/aws-login/pkg/prompt"
+	"github.com/michalschott/aws-login/pkg/random"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// defaultRegion is used when neither -region, the profile nor the
+// environment set one. It is not exported with the credentials.
+const defaultRegion = "us-east-1"
+
+// options are the flags shared by all commands obtaining credentials.
+type options struct {
+	MfaValue        string
+	MfaSerial       string
+	MfaStdin        bool
+	Totp            bool
+	TotpSkew        time.Duration
+	Duration        durations
+	Debug           bool
+	Role            string
+	Profile         string
+	Account         string
+	RoleSessionName string
+	NoUnset         bool
+	NoCache         bool
+	RefreshWindow   time.Duration
+	WriteProfile    string
+	Format          string
+	NoAgent         bool
+	Region          string
+	Tags            tags
+	TransitiveTags  tags
+	SourceIdentity  sourceIdentity
+	ExternalID      string
+	PolicyFile      string
+	PolicyArns      repeated
+	PolicyPreset    string
+	FederatedName   string
+	network
+
+	// role settings resolved from -profile
+	profileRoles []awsconfig.RoleProfile
+	// the base credentials are a session already authenticated with MFA
+	mfaDone bool
+	// session policy resolved by the client of an agent
+	policy *policy.Policy
+	// get a federation token instead of a session token
+	federate bool
+	// region set by -region, the profile or the environment, exported with
+	// the credentials
+	region string
+}
+
+func (o *options) registerSession(flags *flag.FlagSet) {
+	flags.StringVar(&o.MfaValue, "mfa", "", "Value from MFA device, prompted for on the terminal when MFA is required and no code is given")
+	flags.BoolVar(&o.MfaStdin, "mfa-stdin", false, "Read the MFA code from the first line of stdin")
+	flags.StringVar(&o.MfaSerial, "mfa-serial", "", "MFA device serial (ARN or hardware serial), taken from mfa_serial of the profile or discovered with iam:ListMFADevices when not set")
+	flags.BoolVar(&o.Totp, "totp", false, "Generate the MFA code from the seed stored with 'aws-login mfa add'")
+	flags.DurationVar(&o.TotpSkew, "totp-skew", 0, "Offset added to the local clock when generating MFA codes, e.g. 30s when the clock is a step behind")
+	o.Duration = durations{3600}
+	flags.Var(&o.Duration, "duration", "Session duration in `seconds`, comma separated per role when chaining roles")
+	flags.BoolVar(&o.Debug, "debug", false, "Debug")
+	flags.StringVar(&o.Region, "region", "", "AWS region, taken from the profile or AWS_REGION when not set")
+	flags.BoolVar(&o.NoUnset, "nounset", false, "Should current AWS* env variables be unset before assuming new creds. Used in chain-assume scenarios.")
+	flags.BoolVar(&o.NoCache, "no-cache", false, "Do not reuse or store sessions in the local credential cache")
+	flags.DurationVar(&o.RefreshWindow, "refresh-window", 10*time.Minute, "Request new credentials when cached ones expire within this window")
+	o.registerNetwork(flags)
+}
+
+func (o *options) registerRole(flags *flag.FlagSet) {
+	flags.StringVar(&o.Role, "role", "", "Role to assume, as a name, ACCOUNT:NAME or ARN. A comma separated list is assumed in order, each role with credentials of the previous one")
+	flags.StringVar(&o.Account, "account", "", "Account number (if not set it will use sts.GetCallerIdentity call to figure out currently used accountID")
+	flags.StringVar(&o.Profile, "profile", "", "Assume the role defined by this profile of the shared config file, following its source_profile")
+	flags.StringVar(&o.RoleSessionName, "session-name", "", "Session name when assuming role, comma separated per role when chaining roles")
+	flags.Var(&o.Tags, "tag", "Session tag as `KEY=VALUE` of the last role, repeatable")
+	flags.Var(&o.TransitiveTags, "transitive-tag", "Transitive session tag as `KEY=VALUE`, passed on to all roles of a chain, repeatable")
+	flags.Var(&o.SourceIdentity, "source-identity", "Set the source identity to the caller's user name, or to NAME with -source-identity=NAME")
+	flags.StringVar(&o.ExternalID, "external-id", "", "External ID of the last role")
+	o.registerPolicy(flags)
+}
+
+func (o *options) registerAgent(flags *flag.FlagSet) {
+	flags.BoolVar(&o.NoAgent, "no-agent", false, "Log in directly even if an agent is running")
+}
+
+func (o *options) registerOutput(flags *flag.FlagSet) {
+	flags.StringVar(&o.WriteProfile, "write-profile", "", "Write credentials into this profile of the shared credentials file instead of printing them")
+	flags.StringVar(&o.Format, "format", format.Default, "Output format, one of: "+strings.Join(format.Names(), ", "))
+}
+
+// prepare configures logging and the environment the AWS SDK reads its base
+// credentials from.
+func (o *options) prepare() error {
+	setupLogging(o.Debug)
+
+	log.Debugf("aws-login: %s, commit %s, build on %s", version, commit, date)
+
+	if o.MfaValue != "" && !validMfaCode(o.MfaValue) {
+		return withClass(errMfa, fmt.Errorf("invalid MFA code %q, expected 6 digits", o.MfaValue))
+	}
+
+	if o.Profile != "" {
+		if err := o.resolveProfile(); err != nil {
+			return err
+		}
+	}
+
+	// check if AWS_PROFILE is set
+	if os.Getenv("AWS_PROFILE") == "" {
+		log.Info("AWS_PROFILE is not set, defaulting to 'default'.")
+		err := os.Setenv("AWS_PROFILE", "default")
+		if err != nil {
+			return err
+		}
+	}
+
+	if o.WriteProfile != "" && o.WriteProfile == os.Getenv("AWS_PROFILE") {
+		return withClass(errUsage, fmt.Errorf("refusing to overwrite base profile %s with temporary credentials", o.WriteProfile))
+	}
+
+	// unset old/invalid/expired variables
+	log.Debug("Unset variables is set to ", o.NoUnset)
+	if !o.NoUnset {
+		envs := []string{
+			"AWS_ACCESS_KEY_ID",
+			"AWS_SECRET_ACCESS_KEY",
+			"AWS_SESSION_TOKEN",
+		}
+		for _, v := range envs {
+			err := os.Unsetenv(v)
+			if err != nil {
+				log.Info("Can not unset env var ", v)
+			}
+		}
+	}
+
+	return nil
+}
+
+// resolveProfile reads role settings of -profile and points AWS_PROFILE at
+// the profile holding its base credentials.
+func (o *options) resolveProfile() error {
+	if o.Role != "" {
+		return withClass(errUsage, errors.New("-profile and -role can not be used together"))
+	}
+
+	path, err := awsconfig.ConfigPath()
+	if err != nil {
+		return err
+	}
+
+	f, err := awsconfig.Load(path)
+	if err != nil {
+		return withClass(errConfig, err)
+	}
+
+	source, roles, err := f.ResolveRole(o.Profile)
+	if err != nil {
+		return withClass(errConfig, err)
+	}
+	log.Debugf("Profile %s uses base credentials of profile %s and assumes %d roles.", o.Profile, source, len(roles))
+
+	o.profileRoles = roles
+	return os.Setenv("AWS_PROFILE", source)
+}
+
+// config loads the AWS SDK configuration holding the base credentials. STS is
+// called on -endpoint-url, or on the endpoint of the configured region, or of
+// defaultRegion when there is none.
+func (o *options) config(ctx context.Context) (aws.Config, error) {
+	opts, err := o.loadOptions()
+	if err != nil {
+		return aws.Config{}, err
+	}
+	// the region of -profile takes precedence over the one of the profile
+	// holding the base credentials, which AWS_PROFILE points at
+	if region := configuredRegion(o.Region, o.Profile); region != "" {
+		opts = append(opts, config.WithRegion(region))
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx, opts...)
+	var notExist config.SharedConfigProfileNotExistError
+	if errors.As(err, &notExist) && notExist.Profile == "default" {
+		// AWS_PROFILE defaults to a profile that need not exist when the
+		// credentials come from the environment
+		log.Debug("No default profile in the shared config files.")
+		_ = os.Unsetenv("AWS_PROFILE")
+		cfg, err = config.LoadDefaultConfig(ctx, opts...)
+		_ = os.Setenv("AWS_PROFILE", "default")
+	}
+	if err != nil {
+		return cfg, withClass(errConfig, err)
+	}
+
+	o.region = cfg.Region
+	if cfg.Region == "" {
+		log.Debugf("No region configured, using %s.", defaultRegion)
+		cfg.Region = defaultRegion
+	}
+	return cfg, nil
+}
+
+// configuredRegion returns the region set by the flag, the environment or
+// the region setting of profile, like the SDK resolves it. sso_region is the
+// region of IAM Identity Center, not of the credentials, so it is not used.
+func configuredRegion(flag, profile string) string {
+	if flag != "" {
+		return flag
+	}
+	for _, name := range []string{"AWS_REGION", "AWS_DEFAULT_REGION"} {
+		if region := os.Getenv(name); region != "" {
+			return region
+		}
+	}
+	if profile == "" {
+		return ""
+	}
+
+	path, err := awsconfig.ConfigPath()
+	if err != nil {
+		return ""
+	}
+	f, err := awsconfig.Load(path)
+	if err != nil {
+		log.Debug("Can not read config file: ", err)
+		return ""
+	}
+	region, _ := f.Get(awsconfig.ProfileSection(profile), "region")
+	return region
+}
+
+// login gets a session token, or assumes a role when one is set.
+func (o *options) login(ctx context.Context, cfg aws.Config) (*credentials, error) {
+	var err error
+	if cfg.Credentials == nil {
+		return nil, withClass(errNoCredentials, fmt.Errorf("no base credentials found for profile %s", os.Getenv("AWS_PROFILE")))
+	}
+	if _, err := cfg.Credentials.Retrieve(ctx); err != nil {
+		if classify(err) != nil {
+			return nil, err
+		}
+		return nil, withClass(errNoCredentials, fmt.Errorf("can not load base credentials: %w", err))
+	}
+	stsSvc := sts.NewFromConfig(cfg, o.stsOptions)
+
+	// caller identity is needed for the current account and the cache key
+	callerArn := ""
+	account := o.Account
+	if (o.Role != "" && account == "") || !o.NoCache || o.SourceIdentity == callerSourceIdentity {
+		c, err := login.CallerIdentity(ctx, stsSvc)
+		if err != nil {
+			return nil, err
+		}
+
+		callerArn = c.Arn
+		if account == "" {
+			account = c.Account
+		}
+	}
+
+	// if MFA is used, figure out MFA serial first
+	MfaSerial := ""
+	if !o.mfaDone {
+		MfaSerial = o.configuredMfaSerial()
+	}
+	useMfa := !o.mfaDone && (o.MfaValue != "" || o.MfaStdin || o.Totp || MfaSerial != "")
+	if useMfa && MfaSerial == "" {
+		MfaSerial, err = discoverMfaSerial(ctx, cfg)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var chain []login.Role
+	switch {
+	case len(o.profileRoles) > 0:
+		chain, err = profileChain(o.profileRoles, o.Duration)
+	case o.Role != "":
+		chain, err = roleChain(o.Role, o.RoleSessionName, o.Duration, partition(cfg.Region, callerArn), account)
+	}
+	if err != nil {
+		return nil, err
+	}
+	source := string(o.SourceIdentity)
+	if source == callerSourceIdentity {
+		if source, err = callerName(callerArn); err != nil {
+			return nil, withClass(errUsage, fmt.Errorf("%w, set -source-identity=NAME", err))
+		}
+	}
+	if err := sessionSettings(chain, o.Tags, o.TransitiveTags, source, o.ExternalID); err != nil {
+		return nil, err
+	}
+	sessionPolicy, err := o.sessionPolicy(partition(cfg.Region, callerArn))
+	if err != nil {
+		return nil, err
+	}
+	if err := applyPolicy(chain, sessionPolicy); err != nil {
+		return nil, err
+	}
+	var sessionCache *cache.Cache
+	cacheKey := cache.Key{
+		SourceIdentity: callerArn,
+		Roles:          cacheRoles(chain),
+		MfaSerial:      MfaSerial,
+		Duration:       o.Duration.hop(max(len(chain)-1, 0)),
+	}
+	if len(chain) > 0 {
+		cacheKey.Account = account
+	}
+	if !o.NoCache {
+		sessionCache, err = cache.New()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var entry *cache.Entry
+	if sessionCache != nil {
+		entry, err = sessionCache.Get(cacheKey, o.RefreshWindow)
+		if err != nil {
+			log.Info("Can not read credential cache: ", err)
+		}
+	}
+
+	// the MFA code is only obtained when STS has to be called
+	token := &mfaToken{used: func() {}}
+	if entry == nil && useMfa {
+		token, err = o.mfaToken(MfaSerial)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	credentials := new(credentials)
+
+	if entry != nil {
+		log.Debug("Using cached credentials expiring at ", entry.Expiration)
+		credentials.New(entry.AccessKeyID, entry.SecretAccessKey, entry.SessionToken, entry.Expiration)
+	} else {
+		duration, err := random.IntToInt32(o.Duration.hop(0))
+		if err != nil {
+			return nil, err
+		}
+		for i, h := range chain {
+			if (i > 0 || isRoleSession(callerArn)) && h.Duration > maxChainedDuration {
+				log.Warnf("Duration of %d seconds for %s exceeds the one hour limit of role chaining, using %d seconds.", h.Duration, h.Arn, maxChainedDuration)
+				chain[i].Duration = maxChainedDuration
+			}
+		}
+
+		loginOptions := login.Options{
+			Client:    stsSvc,
+			NewClient: login.ClientFor(cfg, o.stsOptions),
+			Roles:     chain,
+			Duration:  duration,
+		}
+		if useMfa {
+			loginOptions.MFA = &login.MFA{Serial: MfaSerial, Call: token.call}
+		}
+		log.Debugf("Logging in with duration %d and roles %+v", duration, chain)
+
+		result, err := login.Login(ctx, loginOptions)
+		if err != nil {
+			return nil, err
+		}
+		credentials.New(result.AccessKeyID, result.SecretAccessKey, result.SessionToken, result.Expiration)
+	}
+
+	credentials.region = o.region
+	if sessionCache != nil && entry == nil {
+		err := sessionCache.Put(cache.Entry{
+			Key:             cacheKey,
+			AccessKeyID:     credentials.awsAccessKeyId,
+			SecretAccessKey: credentials.awsSecretAccessKey,
+			SessionToken:    credentials.awsSessionToken,
+			Expiration:      credentials.expiration,
+		})
+		if err != nil {
+			log.Info("Can not write credential cache: ", err)
+		}
+	}
+
+	return credentials, nil
+}
+
+// configuredMfaSerial returns the MFA serial set with -mfa-serial, or with
+// mfa_serial in the assumed role profiles or the base profile.
+func (o *options) configuredMfaSerial() string {
+	if o.MfaSerial != "" {
+		return o.MfaSerial
+	}
+
+	for _, p := range o.profileRoles {
+		if p.MfaSerial != "" {
+			return p.MfaSerial
+		}
+	}
+
+	path, err := awsconfig.ConfigPath()
+	if err != nil {
+		return ""
+	}
+	f, err := awsconfig.Load(path)
+	if err != nil {
+		log.Debug("Can not read config file: ", err)
+		return ""
+	}
+	serial, _ := f.Get(awsconfig.ProfileSection(os.Getenv("AWS_PROFILE")), "mfa_serial")
+	return serial
+}
+
+// mfaToken returns the code given with -mfa or on stdin, or generates one
+// from the stored seed of serial. Without either the code is prompted for on
+// the terminal.
+func (o *options) mfaToken(serial string) (*mfaToken, error) {
+	switch {
+	case o.MfaValue != "":
+		return &mfaToken{code: o.MfaValue, used: func() {}}, nil
+	case o.MfaStdin:
+		code, err := prompt.ReadLine(os.Stdin)
+		if err != nil {
+			return nil, withClass(errMfa, fmt.Errorf("can not read MFA code from stdin: %w", err))
+		}
+		return newMfaToken(strings.TrimSpace(code))
+	}
+
+	store, err := keyring.Open(os.Getenv("AWS_LOGIN_MFA_STORE"), passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	code, used, err := totpCode(store, serial, o.TotpSkew)
+	switch {
+	case errors.Is(err, keyring.ErrNotFound) && o.Totp:
+		return nil, withClass(errMfa, fmt.Errorf("no seed stored for %s, use 'aws-login mfa add'", serial))
+	case errors.Is(err, keyring.ErrNotFound):
+		log.Debug("No seed stored for ", serial)
+		return promptMfaToken(serial)
+	case err != nil:
+		return nil, err
+	}
+	return &mfaToken{code: code, used: used}, nil
+}
+
+// output prints credentials in the requested format or writes them into a
+// profile.
+func (o *options) output(credentials *credentials) error {
+	if o.WriteProfile != "" {
+		if err := credentials.WriteProfile(o.WriteProfile); err != nil {
+			return err
+		}
+		log.Infof("Credentials written to profile %s.", o.WriteProfile)
+		return nil
+	}
+
+	formatter, err := format.Get(o.Format)
+	if err != nil {
+		return err
+	}
+	return credentials.Print(os.Stdout, formatter)
+}
+
+// run is the action of the session and assume commands.
+func (o *options) run() error {
+	// fail on a bad format before spending an MFA code
+	if _, err := format.Get(o.Format); err != nil {
+		return err
+	}
+
+	if err := o.prepare(); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	cfg, err := o.config(ctx)
+	if err != nil {
+		return err
+	}
+	credentials, err := o.obtain(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	return o.output(credentials)
+}
+
+// obtain asks the agent for credentials when one is running and logs in
+// otherwise.
+func (o *options) obtain(ctx context.Context, cfg aws.Config) (*credentials, error) {
+	if o.federate {
+		// the agent holds a session, which can not get federation tokens
+		return o.federation(ctx, cfg)
+	}
+	if !o.NoAgent {
+		credentials, err := o.fromAgent()
+		if credentials != nil || err != nil {
+			return credentials, err
+		}
+	}
+	return o.login(ctx, cfg)
+}
+
+// fromAgent requests credentials from a running agent. It returns nil when
+// there is no agent, or when the agent holds other base credentials.
+func (o *options) fromAgent() (*credentials, error) {
+	path, err := agent.SocketPath()
+	if err != nil {
+		return nil, nil
+	}
+	client, err := agent.Dial(path)
+	if err != nil {
+		log.Debug("No agent running: ", err)
+		return nil, nil
+	}
+	defer func() { _ = client.Close() }()
+
+	sessionPolicy, err := o.sessionPolicy(identity.PartitionOf(o.region))
+	if err != nil {
+		return nil, err
+	}
+	if o.Role == "" && o.Profile == "" {
+		// the agent would answer with its base session
+		if err := sessionSettings(nil, o.Tags, o.TransitiveTags, string(o.SourceIdentity), o.ExternalID); err != nil {
+			return nil, err
+		}
+		if err := applyPolicy(nil, sessionPolicy); err != nil {
+			return nil, err
+		}
+	}
+
+	log.Debug("Requesting credentials from the agent on ", path)
+	if o.MfaValue != "" || o.MfaStdin || o.Totp {
+		log.Info("The agent handles MFA itself, the MFA code is not used.")
+	}
+
+	response, err := client.Do(agent.Request{
+		Op:          agent.OpCredentials,
+		BaseProfile: os.Getenv("AWS_PROFILE"),
+		Region:      o.region,
+		MfaSerial:   o.MfaSerial,
+		Role:        o.Role,
+		Profile:     o.Profile,
+		Account:     o.Account,
+		SessionName: o.RoleSessionName,
+		Duration:    o.Duration,
+
+		Tags:           o.Tags.pairs(),
+		TransitiveTags: o.TransitiveTags.pairs(),
+		SourceIdentity: string(o.SourceIdentity),
+		ExternalID:     o.ExternalID,
+		Policy:         sessionPolicy.Document,
+		PolicyArns:     sessionPolicy.Arns,
+	})
+	var agentErr *agent.Error
+	if errors.As(err, &agentErr) && agentErr.Class == agent.ClassMismatch {
+		log.Infof("Not using the agent on %s, logging in directly: %s", path, agentErr.Message)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c := response.Credentials
+	credentials := new(credentials)
+	credentials.New(c.AccessKeyID, c.SecretAccessKey, c.SessionToken, c.Expiration)
+	credentials.region = o.region
+	return credentials, nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"os"
+	"testing"
+)
+
+func TestConfiguredRegion(t *testing.T) {
+	isolateConfig(t)
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_DEFAULT_REGION", "")
+	file := "[profile dev]\nsso_session = corp\nsso_region = us-east-1\nregion = eu-central-1\n"
+	if err := os.WriteFile(os.Getenv("AWS_CONFIG_FILE"), []byte(file), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		flag    string
+		profile string
+		env     string
+		want    string
+	}{
+		{},
+		{profile: "dev", want: "eu-central-1"},
+		{profile: "dev", env: "eu-west-1", want: "eu-west-1"},
+		{flag: "ap-south-1", profile: "dev", env: "eu-west-1", want: "ap-south-1"},
+		{profile: "missing"},
+	}
+
+	for _, tt := range tests {
+		t.Setenv("AWS_REGION", tt.env)
+		if got := configuredRegion(tt.flag, tt.profile); got != tt.want {
+			t.Errorf("flag %q, profile %q, AWS_REGION %q: got %q, expected %q", tt.flag, tt.profile, tt.env, got, tt.want)
+		}
+	}
+}
+
+func TestConfigProfileRegion(t *testing.T) {
+	isolateConfig(t)
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_DEFAULT_REGION", "")
+	file := "[profile base]\nregion = us-west-2\n" +
+		"[profile admin]\nrole_arn = arn:aws:iam::222222222222:role/admin\nsource_profile = base\nregion = eu-central-1\n" +
+		"[profile viewer]\nrole_arn = arn:aws:iam::222222222222:role/viewer\nsource_profile = base\n"
+	if err := os.WriteFile(os.Getenv("AWS_CONFIG_FILE"), []byte(file), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		profile string
+		flag    string
+		want    string
+	}{
+		{profile: "admin", want: "eu-central-1"},
+		{profile: "viewer", want: "us-west-2"},
+		{profile: "admin", flag: "ap-south-1", want: "ap-south-1"},
+	}
+
+	for _, tt := range tests {
+		t.Setenv("AWS_PROFILE", "")
+		o := &options{Profile: tt.profile, Region: tt.flag, NoUnset: true}
+		if err := o.prepare(); err != nil {
+			t.Fatal(err)
+		}
+		cfg, err := o.config(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if o.region != tt.want || cfg.Region != tt.want {
+			t.Errorf("profile %s, -region %q: got %q and %q, expected %q", tt.profile, tt.flag, o.region, cfg.Region, tt.want)
+		}
+	}
+}
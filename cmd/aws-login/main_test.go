@@ -3,6 +3,9 @@ package main
 import (
 	"bytes"
 	"testing"
+	"time"
+
+	"github.com/michalschott/aws-login/pkg/format"
 )
 
 func TestCredentialsPrint(t *testing.T) {
@@ -20,11 +23,18 @@ func TestCredentialsPrint(t *testing.T) {
 		{"export AWS_ACCESS_KEY_ID=accessKeyId\nexport AWS_SECRET_ACCESS_KEY=secretAccessKey\nexport AWS_SESSION_TOKEN=session\n"},
 	}
 
+	formatter, err := format.Get(format.Default)
+	if err != nil {
+		t.Fatal(err)
+	}
+
 	for i, tc := range tt {
 		var output bytes.Buffer
 		c := new(credentials)
-		c.New(tc.keyId, tc.secretId, tc.session)
-		c.Print(&output)
+		c.New(tc.keyId, tc.secretId, tc.session, time.Time{})
+		if err := c.Print(&output, formatter); err != nil {
+			t.Fatal(err)
+		}
 
 		if output.String() != expected[i].output {
 			t.Errorf("got %s but expected %s", output.String(), expected[i].output)
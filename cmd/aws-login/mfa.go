@@ -0,0 +1,344 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/smithy-go"
+	"golang.org/x/term"
+
+	"github.com/michalschott/aws-login/pkg/cache"
+	"github.com/michalschott/aws-login/pkg/keyring"
+	"github.com/michalschott/aws-login/pkg/prompt"
+	"github.com/michalschott/aws-login/pkg/totp"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// totpMargin is the minimum remaining lifetime of a generated code. Codes
+// expiring sooner are skipped in favour of the next one.
+const totpMargin = 3 * time.Second
+
+func mfaCommand(flags *flag.FlagSet) func([]string) error {
+	Serial := flags.String("serial", "", "MFA device serial, discovered with iam:ListMFADevices when not set")
+	Store := flags.String("store", os.Getenv("AWS_LOGIN_MFA_STORE"), "Secret store, one of: "+strings.Join(keyring.Backends, ", ")+" (default from AWS_LOGIN_MFA_STORE or auto)")
+	Skew := flags.Duration("totp-skew", 0, "Offset added to the local clock when generating codes, e.g. 30s when the clock is a step behind")
+
+	return func(args []string) error {
+		if len(args) == 0 {
+			return withClass(errUsage, errors.New("expected add, remove or code"))
+		}
+
+		// flags may also follow the subcommand
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+
+		serial := *Serial
+		if serial == "" {
+			var err error
+			serial, err = callerMfaSerial()
+			if err != nil {
+				return err
+			}
+		}
+
+		store, err := keyring.Open(*Store, passphrase)
+		if err != nil {
+			return err
+		}
+
+		switch args[0] {
+		case "add":
+			return mfaAdd(store, serial, flags.Args())
+		case "remove":
+			if err := store.Delete(serial); err != nil {
+				return err
+			}
+			log.Infof("Removed MFA seed of %s.", serial)
+			return nil
+		case "code":
+			code, _, err := totpCode(store, serial, *Skew)
+			if err != nil {
+				return err
+			}
+			fmt.Println(code)
+			return nil
+		default:
+			return withClass(errUsage, fmt.Errorf("unknown mfa command %q, expected add, remove or code", args[0]))
+		}
+	}
+}
+
+// mfaAdd stores the otpauth:// URI given as argument, or read from stdin to
+// keep it out of the shell history. The URI holds the seed, so it is not
+// echoed when typed on a terminal.
+func mfaAdd(store keyring.Store, serial string, args []string) error {
+	uri := ""
+	switch len(args) {
+	case 0:
+		if term.IsTerminal(int(os.Stdin.Fd())) { // #nosec G115 -- file descriptors fit into int
+			b, err := prompt.Password("otpauth:// URI: ")
+			if err != nil {
+				return err
+			}
+			uri = strings.TrimSpace(string(b))
+			break
+		}
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return err
+		}
+		uri = strings.TrimSpace(line)
+	case 1:
+		uri = args[0]
+	default:
+		return withClass(errUsage, errors.New("expected a single otpauth:// URI"))
+	}
+
+	key, err := totp.ParseURI(uri)
+	if err != nil {
+		return err
+	}
+
+	if err := store.Set(serial, uri); err != nil {
+		return err
+	}
+
+	code, err := key.Code(key.Step(time.Now()))
+	if err != nil {
+		return err
+	}
+	log.Infof("Stored MFA seed of %s, the current code is %s.", serial, code)
+	return nil
+}
+
+// callerMfaSerial discovers the MFA device of the current base credentials.
+func callerMfaSerial() (string, error) {
+	o := &options{}
+	if err := o.prepare(); err != nil {
+		return "", err
+	}
+
+	ctx := context.Background()
+	cfg, err := o.config(ctx)
+	if err != nil {
+		return "", err
+	}
+	return discoverMfaSerial(ctx, cfg)
+}
+
+// discoverMfaSerial returns the MFA device of the calling IAM user. It fails
+// when the user has none or several devices, as there is no way to tell which
+// one the code comes from. IAM is called on its regional endpoint, or on
+// AWS_ENDPOINT_URL_IAM, -endpoint-url only applies to STS.
+func discoverMfaSerial(ctx context.Context, cfg aws.Config) (string, error) {
+	result, err := iam.NewFromConfig(cfg).ListMFADevices(ctx, &iam.ListMFADevicesInput{})
+	if err != nil {
+		return "", withClass(errMfa, fmt.Errorf("can not discover MFA device, set -mfa-serial or mfa_serial in the profile: %w", err))
+	}
+
+	serials := []string{}
+	for _, device := range result.MFADevices {
+		serials = append(serials, aws.ToString(device.SerialNumber))
+	}
+
+	switch len(serials) {
+	case 0:
+		return "", withClass(errMfa, errors.New("no MFA device is assigned to the caller"))
+	case 1:
+		log.Debug("Discovered MFA device ", serials[0])
+		return serials[0], nil
+	default:
+		return "", withClass(errMfa, fmt.Errorf("several MFA devices found (%s), choose one with -mfa-serial", strings.Join(serials, ", ")))
+	}
+}
+
+// mfaAttempts is how often a code typed in is asked for when STS rejects it.
+const mfaAttempts = 3
+
+var mfaCodePattern = regexp.MustCompile(`^[0-9]{6}$`)
+
+// mfaToken is an MFA code together with how it was obtained.
+type mfaToken struct {
+	code string
+
+	// used records the code as accepted by STS
+	used func()
+
+	// serial is set when the code was typed in, so a new one can be asked
+	// for when STS rejects it
+	serial string
+}
+
+func newMfaToken(code string) (*mfaToken, error) {
+	if !validMfaCode(code) {
+		return nil, withClass(errMfa, fmt.Errorf("invalid MFA code %q, expected 6 digits", code))
+	}
+	return &mfaToken{code: code, used: func() {}}, nil
+}
+
+// promptMfaToken asks for the code of serial on the terminal until one in the
+// right format is typed in.
+func promptMfaToken(serial string) (*mfaToken, error) {
+	for {
+		code, err := prompt.Line("MFA code for " + serial + ": ")
+		if err != nil {
+			return nil, withClass(errMfa, fmt.Errorf("MFA code required, use -mfa, -mfa-stdin or -totp: %w", err))
+		}
+
+		code = strings.TrimSpace(code)
+		if validMfaCode(code) {
+			return &mfaToken{code: code, used: func() {}, serial: serial}, nil
+		}
+		_, _ = fmt.Fprintln(os.Stderr, "The MFA code has to be 6 digits.")
+	}
+}
+
+func validMfaCode(code string) bool {
+	return mfaCodePattern.MatchString(code)
+}
+
+// call passes the code to fn. When STS rejects a code typed in, a new one is
+// prompted for and fn is called again.
+func (t *mfaToken) call(fn func(code string) error) error {
+	for attempt := 1; ; attempt++ {
+		err := fn(t.code)
+		if err == nil {
+			t.used()
+			return nil
+		}
+		if t.serial == "" || attempt == mfaAttempts || !isMfaFailure(err) {
+			return err
+		}
+
+		log.Warn("MFA code was not accepted: ", err)
+		next, err := promptMfaToken(t.serial)
+		if err != nil {
+			return err
+		}
+		t.code = next.code
+	}
+}
+
+// isMfaFailure reports whether STS rejected a call because of a wrong MFA
+// code. Other denials, like a trust policy or bad access keys, are not worth
+// another code.
+func isMfaFailure(err error) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && mfaRejected(apiErr)
+}
+
+// mfaRejected reports whether apiErr is how STS rejects a wrong MFA code, an
+// AccessDenied naming MultiFactorAuthentication.
+func mfaRejected(apiErr smithy.APIError) bool {
+	return apiErr.ErrorCode() == "AccessDenied" && strings.Contains(apiErr.ErrorMessage(), "MultiFactorAuthentication")
+}
+
+// passphrase unlocks the file secret store.
+func passphrase() ([]byte, error) {
+	if p := os.Getenv("AWS_LOGIN_PASSPHRASE"); p != "" {
+		return []byte(p), nil
+	}
+	return prompt.Password("Passphrase for the aws-login secret store: ")
+}
+
+// totpCode generates a code from the stored seed of serial at the local time
+// shifted by skew. It waits for the next time step when the current code was
+// already accepted by STS. The returned function records the code as used.
+func totpCode(store keyring.Store, serial string, skew time.Duration) (string, func(), error) {
+	uri, err := store.Get(serial)
+	if err != nil {
+		return "", nil, err
+	}
+
+	key, err := totp.ParseURI(uri)
+	if err != nil {
+		return "", nil, err
+	}
+
+	steps, err := loadTotpSteps()
+	if err != nil {
+		log.Info("Can not read used MFA codes: ", err)
+	}
+
+	// codes are only accepted for the time step of the AWS clock
+	step, wait := key.Next(time.Now().Add(skew), steps[serial], totpMargin)
+	if wait > 0 {
+		log.Infof("Waiting %s for the next MFA code.", wait.Round(time.Second))
+		time.Sleep(wait)
+	}
+
+	code, err := key.Code(step)
+	if err != nil {
+		return "", nil, err
+	}
+
+	used := func() {
+		steps[serial] = step
+		if err := saveTotpSteps(steps); err != nil {
+			log.Info("Can not record used MFA code: ", err)
+		}
+	}
+	return code, used, nil
+}
+
+// The last used time step of every MFA device is kept next to the credential
+// cache.
+func totpStepsPath() (string, error) {
+	c, err := cache.New()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(c.Dir, "totp.state"), nil
+}
+
+func loadTotpSteps() (map[string]int64, error) {
+	steps := map[string]int64{}
+
+	path, err := totpStepsPath()
+	if err != nil {
+		return steps, err
+	}
+
+	b, err := os.ReadFile(filepath.Clean(path))
+	if errors.Is(err, os.ErrNotExist) {
+		return steps, nil
+	}
+	if err != nil {
+		return steps, err
+	}
+
+	if err := json.Unmarshal(b, &steps); err != nil {
+		return map[string]int64{}, err
+	}
+	return steps, nil
+}
+
+func saveTotpSteps(steps map[string]int64) error {
+	path, err := totpStepsPath()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(steps)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0600)
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/aws/smithy-go"
+)
+
+func TestNewMfaToken(t *testing.T) {
+	tests := []struct {
+		code    string
+		wantErr bool
+	}{
+		{code: "123456"},
+		{code: "012345"},
+		{code: "12345", wantErr: true},
+		{code: "1234567", wantErr: true},
+		{code: "12345a", wantErr: true},
+		{code: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		_, err := newMfaToken(tt.code)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("err is wrong, value=%v, wantErr=%v, err=%v", tt.code, tt.wantErr, err)
+		}
+	}
+}
+
+func TestIsMfaFailure(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected bool
+	}{
+		{err: &smithy.GenericAPIError{Code: "AccessDenied", Message: "MultiFactorAuthentication failed with invalid MFA one time pass code."}, expected: true},
+		{err: fmt.Errorf("assuming role: %w", &smithy.GenericAPIError{Code: "AccessDenied", Message: "MultiFactorAuthentication failed"}), expected: true},
+		{err: &smithy.GenericAPIError{Code: "AccessDenied", Message: "User is not authorized to perform: sts:AssumeRole"}},
+		{err: &smithy.GenericAPIError{Code: "AccessDenied"}},
+		{err: fmt.Errorf("assuming role: %w", &smithy.GenericAPIError{Code: "InvalidClientTokenId"})},
+		{err: &smithy.GenericAPIError{Code: "ExpiredToken"}},
+		{err: errors.New("AccessDenied")},
+	}
+
+	for _, tt := range tests {
+		if got := isMfaFailure(tt.err); got != tt.expected {
+			t.Errorf("isMfaFailure(%v) = %v, expected %v", tt.err, got, tt.expected)
+		}
+	}
+}
+
+func TestMfaTokenCall(t *testing.T) {
+	denied := &smithy.GenericAPIError{Code: "AccessDenied"}
+
+	// codes not typed in are never asked for again
+	used, calls := false, 0
+	token := &mfaToken{code: "123456", used: func() { used = true }}
+	err := token.call(func(code string) error {
+		calls++
+		return denied
+	})
+	if !errors.Is(err, denied) || calls != 1 || used {
+		t.Errorf("got err=%v, calls=%d, used=%v", err, calls, used)
+	}
+
+	err = token.call(func(code string) error {
+		if code != "123456" {
+			t.Errorf("got code %s", code)
+		}
+		return nil
+	})
+	if err != nil || !used {
+		t.Errorf("got err=%v, used=%v", err, used)
+	}
+
+	// a typed code is only asked for again when STS rejected the code itself,
+	// a new code does not help against a trust policy or bad access keys
+	for _, err := range []error{
+		&smithy.GenericAPIError{Code: "AccessDenied", Message: "User: arn:aws:iam::111111111111:user/alice is not authorized to perform: sts:AssumeRole"},
+		&smithy.GenericAPIError{Code: "InvalidClientTokenId", Message: "The security token included in the request is invalid."},
+	} {
+		calls := 0
+		token := &mfaToken{code: "123456", used: func() {}, serial: "arn:aws:iam::111111111111:mfa/alice"}
+		got := token.call(func(code string) error {
+			calls++
+			return err
+		})
+		if !errors.Is(got, err) || calls != 1 {
+			t.Errorf("%v: got err=%v after %d calls, expected no new prompt", err, got, calls)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// httpTimeout limits every request, including reading the response.
+const httpTimeout = 30 * time.Second
+
+// network are the flags for reaching AWS from restricted networks. The SDK
+// reads AWS_ENDPOINT_URL_STS, AWS_USE_FIPS_ENDPOINT,
+// AWS_USE_DUALSTACK_ENDPOINT, AWS_CA_BUNDLE and HTTPS_PROXY by itself, the
+// flags take precedence over them.
+type network struct {
+	EndpointURL  string
+	UseFIPS      bool
+	UseDualStack bool
+	Proxy        string
+	CaBundle     string
+}
+
+func (n *network) registerNetwork(flags *flag.FlagSet) {
+	flags.StringVar(&n.EndpointURL, "endpoint-url", "", "STS endpoint `URL`, such as a VPC interface endpoint, taken from AWS_ENDPOINT_URL_STS when not set")
+	n.registerTransport(flags)
+}
+
+// registerTransport registers the flags of the network other than the STS
+// endpoint, for commands that do not call STS.
+func (n *network) registerTransport(flags *flag.FlagSet) {
+	flags.BoolVar(&n.UseFIPS, "use-fips", false, "Use FIPS endpoints")
+	flags.BoolVar(&n.UseDualStack, "use-dualstack", false, "Use dual-stack (IPv4 and IPv6) endpoints")
+	flags.StringVar(&n.Proxy, "proxy", "", "HTTPS proxy `URL`, taken from HTTPS_PROXY when not set")
+	flags.StringVar(&n.CaBundle, "ca-bundle", "", "PEM `file` of certificates to trust in addition to the system ones, taken from AWS_CA_BUNDLE when not set")
+}
+
+// loadOptions returns the SDK configuration options for the flags.
+func (n *network) loadOptions() ([]func(*config.LoadOptions) error, error) {
+	opts := []func(*config.LoadOptions) error{}
+	if n.EndpointURL != "" {
+		if err := validURL(n.EndpointURL); err != nil {
+			return nil, withClass(errUsage, fmt.Errorf("invalid -endpoint-url: %w", err))
+		}
+		log.Debugf("Calling STS at %s.", n.EndpointURL)
+	}
+	if n.UseFIPS {
+		opts = append(opts, config.WithUseFIPSEndpoint(aws.FIPSEndpointStateEnabled))
+	}
+	if n.UseDualStack {
+		opts = append(opts, config.WithUseDualStackEndpoint(aws.DualStackEndpointStateEnabled))
+	}
+
+	// the SDK leaves aws.Config.HTTPClient nil without a proxy or CA bundle,
+	// it is also used for requests outside of the SDK
+	client := awshttp.NewBuildableClient().WithTimeout(httpTimeout)
+	if n.Proxy != "" {
+		if err := validURL(n.Proxy); err != nil {
+			return nil, withClass(errUsage, fmt.Errorf("invalid -proxy: %w", err))
+		}
+		proxy, _ := url.Parse(n.Proxy)
+		log.Debugf("Using proxy %s.", proxy.Redacted())
+		client = client.WithTransportOptions(func(tr *http.Transport) {
+			tr.Proxy = http.ProxyURL(proxy)
+		})
+	}
+	opts = append(opts, config.WithHTTPClient(client))
+	if n.CaBundle != "" {
+		pem, err := os.ReadFile(n.CaBundle) // #nosec G304 -- the bundle is chosen by the user
+		if err != nil {
+			return nil, withClass(errConfig, fmt.Errorf("can not read -ca-bundle: %w", err))
+		}
+		// a fresh reader for every load, config may be loaded twice
+		opts = append(opts, func(lo *config.LoadOptions) error {
+			return config.WithCustomCABundle(bytes.NewReader(pem))(lo)
+		})
+	}
+	return opts, nil
+}
+
+// regionConfig loads the AWS SDK configuration for calls to region, such as
+// the ones to IAM Identity Center, through the network of the flags.
+func (n *network) regionConfig(ctx context.Context, region string) (aws.Config, error) {
+	opts, err := n.loadOptions()
+	if err != nil {
+		return aws.Config{}, err
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx, append(opts, config.WithRegion(region))...)
+	if err != nil {
+		return cfg, withClass(errConfig, err)
+	}
+	return cfg, nil
+}
+
+// stsOptions points STS clients to -endpoint-url.
+func (n *network) stsOptions(o *sts.Options) {
+	if n.EndpointURL != "" {
+		o.BaseEndpoint = aws.String(n.EndpointURL)
+	}
+}
+
+func validURL(s string) error {
+	u, err := url.Parse(s)
+	if err != nil {
+		return err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("expected an http or https URL")
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"encoding/pem"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+
+	"github.com/michalschott/aws-login/pkg/login"
+)
+
+// stsStandIn answers every request with the identity of alice and counts the
+// requests.
+func stsStandIn(requests *int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		*requests++
+		w.Header().Set("Content-Type", "text/xml")
+		_, _ = w.Write([]byte(`<GetCallerIdentityResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/"><GetCallerIdentityResult>` +
+			`<Arn>arn:aws:iam::111111111111:user/alice</Arn><UserId>AIDAFAKE</UserId><Account>111111111111</Account>` +
+			`</GetCallerIdentityResult></GetCallerIdentityResponse>`))
+	}
+}
+
+// isolateConfig keeps shared config files and endpoint settings of the
+// environment out of a test and sets static base credentials.
+func isolateConfig(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(dir, "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(dir, "credentials"))
+	t.Setenv("AWS_PROFILE", "")
+	t.Setenv("AWS_ENDPOINT_URL", "")
+	t.Setenv("AWS_ENDPOINT_URL_STS", "")
+	t.Setenv("AWS_CA_BUNDLE", "")
+	t.Setenv("HTTPS_PROXY", "")
+	t.Setenv("HTTP_PROXY", "")
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIAFAKE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_SESSION_TOKEN", "")
+}
+
+// callerIdentity loads the config of n and calls STS with it.
+func callerIdentity(t *testing.T, n network) (*login.Identity, error) {
+	t.Helper()
+	o := &options{Region: "eu-west-1", network: n}
+	cfg, err := o.config(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.HTTPClient == nil {
+		t.Fatal("config has no HTTP client")
+	}
+	// failures are expected by some tests, do not wait for retries
+	return login.CallerIdentity(context.Background(), sts.NewFromConfig(cfg, o.stsOptions, func(so *sts.Options) { so.RetryMaxAttempts = 1 }))
+}
+
+func TestNetworkEndpointURL(t *testing.T) {
+	isolateConfig(t)
+	requests := 0
+	srv := httptest.NewServer(stsStandIn(&requests))
+	defer srv.Close()
+
+	id, err := callerIdentity(t, network{EndpointURL: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if requests != 1 || id.Account != "111111111111" {
+		t.Errorf("got %+v after %d requests to the endpoint", id, requests)
+	}
+}
+
+func TestNetworkProxy(t *testing.T) {
+	isolateConfig(t)
+	requests := 0
+	var target string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// a proxy gets the absolute URL of the target
+		target = r.URL.Host
+		stsStandIn(&requests)(w, r)
+	}))
+	defer proxy.Close()
+
+	// the endpoint can not be resolved, only the proxy reaches it
+	_, err := callerIdentity(t, network{EndpointURL: "http://sts.example.invalid", Proxy: proxy.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if requests != 1 || target != "sts.example.invalid" {
+		t.Errorf("proxy got %d requests for %q", requests, target)
+	}
+}
+
+func TestNetworkCaBundle(t *testing.T) {
+	isolateConfig(t)
+	requests := 0
+	srv := httptest.NewUnstartedServer(stsStandIn(&requests))
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+	srv.StartTLS()
+	defer srv.Close()
+
+	bundle := filepath.Join(t.TempDir(), "ca.pem")
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := os.WriteFile(bundle, cert, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := callerIdentity(t, network{EndpointURL: srv.URL}); err == nil {
+		t.Error("expected the certificate of the test server to be rejected without the bundle")
+	}
+	if _, err := callerIdentity(t, network{EndpointURL: srv.URL, CaBundle: bundle}); err != nil {
+		t.Fatal(err)
+	}
+	if requests != 1 {
+		t.Errorf("server got %d requests, expected 1", requests)
+	}
+}
+
+func TestNetworkInvalid(t *testing.T) {
+	tests := []struct {
+		value   network
+		wantErr *errorClass
+	}{
+		{value: network{EndpointURL: "vpce-1234.sts.eu-west-1.vpce.amazonaws.com"}, wantErr: errUsage},
+		{value: network{Proxy: "proxy.example.com:3128"}, wantErr: errUsage},
+		{value: network{CaBundle: filepath.Join(t.TempDir(), "missing.pem")}, wantErr: errConfig},
+	}
+
+	for _, tt := range tests {
+		_, err := tt.value.loadOptions()
+		if classify(err) != tt.wantErr {
+			t.Errorf("err is wrong, value=%+v, wantErr=%v, err=%v", tt.value, tt.wantErr, err)
+		}
+	}
+}
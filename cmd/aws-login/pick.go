@@ -0,0 +1,256 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ssoapi "github.com/aws/aws-sdk-go-v2/service/sso"
+	"github.com/aws/aws-sdk-go-v2/service/ssooidc"
+
+	"github.com/michalschott/aws-login/pkg/awsconfig"
+	"github.com/michalschott/aws-login/pkg/picker"
+	"github.com/michalschott/aws-login/pkg/sso"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// candidate is a role offered by the picker, either a role profile of the
+// shared config file or an IAM Identity Center account assignment.
+type candidate struct {
+	Source      string `json:"source"`
+	Profile     string `json:"profile,omitempty"`
+	AccountID   string `json:"accountId"`
+	AccountName string `json:"accountName,omitempty"`
+	RoleName    string `json:"roleName"`
+	RoleArn     string `json:"roleArn,omitempty"`
+	StartURL    string `json:"startUrl,omitempty"`
+
+	// login and token are set for IAM Identity Center roles
+	login awsconfig.SSOProfile
+	token *sso.Token
+}
+
+func (c *candidate) key() string {
+	if c.Source == "profile" {
+		return "profile:" + c.Profile
+	}
+	return "sso:" + c.StartURL + ":" + c.AccountID + ":" + c.RoleName
+}
+
+func (c *candidate) label() string {
+	account := c.AccountID
+	if c.AccountName != "" {
+		account = c.AccountName + " (" + c.AccountID + ")"
+	}
+	if c.Source == "profile" {
+		return fmt.Sprintf("%s %s  [profile %s]", account, c.RoleName, c.Profile)
+	}
+	return fmt.Sprintf("%s %s  [sso %s]", account, c.RoleName, c.StartURL)
+}
+
+func pickCommand(flags *flag.FlagSet) func([]string) error {
+	o := &options{}
+	o.registerSession(flags)
+	o.registerAgent(flags)
+	o.registerOutput(flags)
+	Filter := flags.String("filter", "", "Only offer roles matching this query, choosing directly when a single one matches")
+	List := flags.Bool("list", false, "Print the roles as JSON instead of picking one")
+
+	return noArgs(func() error {
+		setupLogging(o.Debug)
+
+		ctx := context.Background()
+		candidates, err := pickCandidates(ctx, &o.network)
+		if err != nil {
+			return err
+		}
+
+		c, err := choose(candidates, *Filter, *List)
+		if c == nil || err != nil {
+			return err
+		}
+
+		if c.Source == "profile" {
+			o.Profile = c.Profile
+			return o.run()
+		}
+
+		cfg, err := o.regionConfig(ctx, c.login.Region)
+		if err != nil {
+			return err
+		}
+		credentials, err := ssoCredentials(ctx, cfg, c.token, c.AccountID, c.RoleName)
+		if err != nil {
+			return err
+		}
+		credentials.region = configuredRegion(o.Region, "")
+		return o.output(credentials)
+	})
+}
+
+// pickCandidates collects the role profiles of the shared config file and
+// the roles of every IAM Identity Center login with a cached token, reached
+// through n.
+func pickCandidates(ctx context.Context, n *network) ([]*candidate, error) {
+	path, err := awsconfig.ConfigPath()
+	if err != nil {
+		return nil, err
+	}
+	f, err := awsconfig.Load(path)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := []*candidate{}
+	for _, name := range f.Profiles() {
+		roleArn, _ := f.Get(awsconfig.ProfileSection(name), "role_arn")
+		if roleArn == "" {
+			continue
+		}
+
+		c := &candidate{Source: "profile", Profile: name, RoleArn: roleArn}
+		// arn:aws:iam::ACCOUNT:role/PATH/NAME
+		if parts := strings.Split(roleArn, ":"); len(parts) == 6 {
+			c.AccountID = parts[4]
+			c.RoleName = parts[5][strings.LastIndex(parts[5], "/")+1:]
+		}
+		candidates = append(candidates, c)
+	}
+
+	for _, login := range f.SSOSessions() {
+		cfg, err := n.regionConfig(ctx, login.Region)
+		if err != nil {
+			return nil, err
+		}
+
+		tokenPath, err := sso.CachePath(login.CacheKey())
+		if err != nil {
+			return nil, err
+		}
+		token := refreshSSOToken(ctx, ssooidc.NewFromConfig(cfg), tokenPath, loadSSOToken(tokenPath, login))
+		if token == nil {
+			log.Infof("Not logged in to %s, run 'aws-login sso' to include its accounts.", login.StartURL)
+			continue
+		}
+
+		roles, err := ssoCandidates(ctx, cfg, login, token)
+		if err != nil {
+			log.Infof("Can not list accounts of %s: %s", login.StartURL, err)
+			continue
+		}
+		candidates = append(candidates, roles...)
+	}
+	return candidates, nil
+}
+
+func ssoCandidates(ctx context.Context, cfg aws.Config, login awsconfig.SSOProfile, token *sso.Token) ([]*candidate, error) {
+	roles, err := sso.Roles(ctx, ssoapi.NewFromConfig(cfg), token)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := []*candidate{}
+	for _, r := range roles {
+		candidates = append(candidates, &candidate{
+			Source:      "sso",
+			AccountID:   r.AccountID,
+			AccountName: r.AccountName,
+			RoleName:    r.RoleName,
+			StartURL:    login.StartURL,
+			login:       login,
+			token:       token,
+		})
+	}
+	return candidates, nil
+}
+
+// pickSSORole lets the user pick one of the roles of an IAM Identity Center
+// login, limited to the account and role set in p.
+func pickSSORole(ctx context.Context, cfg aws.Config, p awsconfig.SSOProfile, token *sso.Token) (*candidate, error) {
+	all, err := ssoCandidates(ctx, cfg, p, token)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := []*candidate{}
+	for _, c := range all {
+		if (p.AccountID == "" || c.AccountID == p.AccountID) && (p.RoleName == "" || c.RoleName == p.RoleName) {
+			candidates = append(candidates, c)
+		}
+	}
+	switch len(candidates) {
+	case 0:
+		return nil, fmt.Errorf("no assigned role matches account %q and role %q", p.AccountID, p.RoleName)
+	case 1:
+		return candidates[0], nil
+	}
+	return choose(candidates, "", false)
+}
+
+// choose lets the user pick one of the candidates matching query, recently
+// chosen ones first. Without a terminal, or with list set, the candidates are
+// printed as JSON and nil is returned. A single match is chosen directly when
+// a query is given.
+func choose(candidates []*candidate, query string, list bool) (*candidate, error) {
+	byKey := map[string]*candidate{}
+	items := []picker.Item{}
+	for _, c := range candidates {
+		byKey[c.key()] = c
+		items = append(items, picker.Item{Key: c.key(), Label: c.label()})
+	}
+
+	recent := recentChoices()
+	if recent != nil {
+		keys, err := recent.Load()
+		if err != nil {
+			log.Info("Can not read recent choices: ", err)
+		}
+		picker.SortRecent(items, keys)
+	}
+	items = picker.Filter(items, query)
+
+	var choice picker.Item
+	switch {
+	case list || (!picker.Interactive() && (query == "" || len(items) != 1)):
+		matches := []*candidate{}
+		for _, item := range items {
+			matches = append(matches, byKey[item.Key])
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return nil, encoder.Encode(matches)
+	case len(items) == 0:
+		return nil, errors.New("no role to pick from")
+	case query != "" && len(items) == 1:
+		choice = items[0]
+	default:
+		var err error
+		if choice, err = picker.Pick(items, "Role"); err != nil {
+			return nil, err
+		}
+	}
+
+	if recent != nil {
+		if err := recent.Add(choice.Key); err != nil {
+			log.Info("Can not record recent choice: ", err)
+		}
+	}
+	return byKey[choice.Key], nil
+}
+
+// recentChoices are kept in the user config directory, away from the session
+// files of the credential cache.
+func recentChoices() *picker.Recent {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return nil
+	}
+	return &picker.Recent{Path: filepath.Join(dir, "aws-login", "recent.json")}
+}
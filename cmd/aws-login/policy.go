@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/michalschott/aws-login/pkg/login"
+	"github.com/michalschott/aws-login/pkg/policy"
+)
+
+// repeated collects the values of a flag given more than once.
+type repeated []string
+
+func (r *repeated) String() string {
+	return strings.Join(*r, ",")
+}
+
+func (r *repeated) Set(value string) error {
+	*r = append(*r, value)
+	return nil
+}
+
+func (o *options) registerPolicy(flags *flag.FlagSet) {
+	flags.StringVar(&o.PolicyFile, "policy-file", "", "Session policy JSON `file`, limiting the session to what both the role and the policy allow")
+	flags.Var(&o.PolicyArns, "policy-arn", "Managed policy `ARN` used as session policy, repeatable")
+	flags.StringVar(&o.PolicyPreset, "policy-preset", "", "Built-in session policy, one of: "+strings.Join(policy.Presets(), ", "))
+}
+
+// sessionPolicy returns the session policy of the flags, with ARNs of presets
+// in partition, or the one sent to the agent.
+func (o *options) sessionPolicy(partition string) (policy.Policy, error) {
+	if o.policy != nil {
+		// the agent gets policies from any client on the socket
+		if err := o.policy.Check(); err != nil {
+			return *o.policy, withClass(errUsage, err)
+		}
+		return *o.policy, nil
+	}
+
+	p := policy.Policy{Arns: o.PolicyArns}
+	if o.PolicyPreset != "" {
+		preset, err := policy.Preset(o.PolicyPreset, partition)
+		if err != nil {
+			return p, withClass(errUsage, err)
+		}
+		p.Document = preset.Document
+		p.Arns = append(preset.Arns, p.Arns...)
+	}
+	if o.PolicyFile != "" {
+		b, err := os.ReadFile(o.PolicyFile) // #nosec G304 -- the policy file is chosen by the user
+		if err != nil {
+			return p, withClass(errUsage, fmt.Errorf("can not read -policy-file: %w", err))
+		}
+		document, err := policy.Parse(b)
+		if err != nil {
+			return p, withClass(errUsage, fmt.Errorf("%s: %w", o.PolicyFile, err))
+		}
+		// statements of the file and of a preset are combined
+		if p.Document == "" {
+			p.Document = document
+		} else if p.Document, err = policy.Merge(p.Document, document); err != nil {
+			return p, withClass(errUsage, err)
+		}
+	}
+
+	if err := p.Check(); err != nil {
+		return p, withClass(errUsage, err)
+	}
+	return p, nil
+}
+
+// applyPolicy limits the last role of chain, whose credentials are returned,
+// to p.
+func applyPolicy(chain []login.Role, p policy.Policy) error {
+	if p.IsZero() {
+		return nil
+	}
+	if len(chain) == 0 {
+		return withClass(errUsage, errors.New("session policies need -role, -profile or the federate command"))
+	}
+	chain[len(chain)-1].Policy = p
+	return nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/michalschott/aws-login/pkg/login"
+	"github.com/michalschott/aws-login/pkg/policy"
+)
+
+func TestSessionPolicy(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "policy.json")
+	if err := os.WriteFile(file, []byte("{\n  \"Statement\": {\"Effect\": \"Deny\", \"Action\": \"s3:DeleteObject\", \"Resource\": \"*\"}\n}\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	broken := filepath.Join(dir, "broken.json")
+	if err := os.WriteFile(broken, []byte(`{"Statement": [`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		value   options
+		want    policy.Policy
+		wantErr bool
+	}{
+		{value: options{}, want: policy.Policy{}},
+		{value: options{PolicyFile: file}, want: policy.Policy{Document: `{"Statement":{"Effect":"Deny","Action":"s3:DeleteObject","Resource":"*"}}`}},
+		{
+			value: options{PolicyPreset: "read-only", PolicyArns: repeated{"arn:aws:iam::111111111111:policy/team"}},
+			want:  policy.Policy{Arns: []string{"arn:aws-us-gov:iam::aws:policy/ReadOnlyAccess", "arn:aws:iam::111111111111:policy/team"}},
+		},
+		{
+			value: options{PolicyPreset: "s3-bucket-only:reports", PolicyFile: file},
+			want: policy.Policy{Document: `{"Statement":[{"Action":"s3:*","Effect":"Allow","Resource":["arn:aws-us-gov:s3:::reports","arn:aws-us-gov:s3:::reports/*"]},` +
+				`{"Effect":"Deny","Action":"s3:DeleteObject","Resource":"*"}],"Version":"2012-10-17"}`},
+		},
+		{value: options{PolicyFile: broken}, wantErr: true},
+		{value: options{PolicyFile: filepath.Join(dir, "missing.json")}, wantErr: true},
+		{value: options{PolicyPreset: "admin"}, wantErr: true},
+		{value: options{PolicyArns: repeated{"ReadOnlyAccess"}}, wantErr: true},
+		{
+			value: options{policy: &policy.Policy{Arns: []string{"arn:aws:iam::aws:policy/ReadOnlyAccess"}}, PolicyPreset: "admin"},
+			want:  policy.Policy{Arns: []string{"arn:aws:iam::aws:policy/ReadOnlyAccess"}},
+		},
+		{value: options{policy: &policy.Policy{Arns: []string{"ReadOnlyAccess"}}}, wantErr: true},
+		{value: options{policy: &policy.Policy{Document: strings.Repeat("x", policy.MaxSize+1)}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := tt.value.sessionPolicy("aws-us-gov")
+		if (err != nil) != tt.wantErr {
+			t.Errorf("err is wrong, value=%+v, wantErr=%v, err=%v", tt.value.PolicyPreset, tt.wantErr, err)
+			continue
+		}
+		if err != nil {
+			if classify(err) != errUsage {
+				t.Errorf("%v is not a usage error", err)
+			}
+			continue
+		}
+		if got.Document != tt.want.Document || strings.Join(got.Arns, ",") != strings.Join(tt.want.Arns, ",") {
+			t.Errorf("got %+v, expected %+v", got, tt.want)
+		}
+	}
+}
+
+func TestApplyPolicy(t *testing.T) {
+	p := policy.Policy{Arns: []string{"arn:aws:iam::aws:policy/ReadOnlyAccess"}}
+	if err := applyPolicy(nil, p); classify(err) != errUsage {
+		t.Errorf("expected a usage error without roles, got %v", err)
+	}
+	if err := applyPolicy(nil, policy.Policy{}); err != nil {
+		t.Errorf("no policy without roles: %v", err)
+	}
+
+	chain := []login.Role{{Arn: "arn:aws:iam::111111111111:role/a"}, {Arn: "arn:aws:iam::111111111111:role/b"}}
+	if err := applyPolicy(chain, p); err != nil {
+		t.Fatal(err)
+	}
+	if !chain[0].Policy.IsZero() || !reflect.DeepEqual(chain[1].Policy, p) {
+		t.Errorf("policy should limit the last role only, got %+v", chain)
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+
+	"github.com/michalschott/aws-login/pkg/format"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// session keeps the credentials obtained by login and gets new ones once they
+// expire within the refresh window.
+type session struct {
+	o   *options
+	cfg aws.Config
+
+	mu          sync.Mutex
+	credentials *credentials
+}
+
+func (s *session) get(ctx context.Context) (format.Credentials, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.credentials != nil && time.Until(s.credentials.expiration) > s.o.RefreshWindow {
+		return s.credentials.Format(), nil
+	}
+
+	credentials, err := s.o.login(ctx, s.cfg)
+	if err != nil {
+		return format.Credentials{}, err
+	}
+	log.Infof("Obtained credentials expiring at %s.", credentials.expiration.Local().Format(time.RFC3339))
+
+	// a code given on the command line can not be used again, later
+	// sessions generate or prompt for one
+	s.o.MfaValue, s.o.MfaStdin = "", false
+
+	s.credentials = credentials
+	return credentials.Format(), nil
+}
+
+// current returns the credentials held by the session, if any.
+func (s *session) current() *credentials {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.credentials
+}
+
+// valid tells whether the session holds credentials outside the refresh
+// window.
+func (s *session) valid() bool {
+	c := s.current()
+	return c != nil && time.Until(c.expiration) > s.o.RefreshWindow
+}
+
+// forget drops the credentials, so the next get logs in again.
+func (s *session) forget() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	held := s.credentials != nil
+	s.credentials = nil
+	return held
+}
+
+// serve answers requests on addr until aws-login is interrupted. The
+// variables pointing clients at the server are printed to stdout.
+func serve(addr string, handler http.Handler, env func(addr string) []format.Variable) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	if host, _, err := net.SplitHostPort(listener.Addr().String()); err == nil {
+		if ip := net.ParseIP(host); ip == nil || !ip.IsLoopback() {
+			log.Warnf("Listening on %s, credentials are reachable from other hosts.", listener.Addr())
+		}
+	}
+
+	for _, v := range env(listener.Addr().String()) {
+		fmt.Printf("%s=%s\n", v.Name, v.Value)
+	}
+	log.Infof("Serving credentials on %s, stop with Ctrl-C.", listener.Addr())
+
+	srv := &http.Server{Handler: handler, ReadHeaderTimeout: 10 * time.Second}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdown)
+	}()
+
+	if err := srv.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ssoapi "github.com/aws/aws-sdk-go-v2/service/sso"
+	ssotypes "github.com/aws/aws-sdk-go-v2/service/sso/types"
+	"github.com/aws/aws-sdk-go-v2/service/ssooidc"
+
+	"github.com/michalschott/aws-login/pkg/awsconfig"
+	"github.com/michalschott/aws-login/pkg/sso"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ssoTokenWindow is the minimum remaining lifetime of a cached access token.
+const ssoTokenWindow = 5 * time.Minute
+
+// ssoOptions are the flags of the sso command.
+type ssoOptions struct {
+	Profile  string
+	StartURL string
+	Region   string
+	Account  string
+	Role     string
+	Force    bool
+}
+
+func ssoCommand(flags *flag.FlagSet) func([]string) error {
+	s := &ssoOptions{}
+	flags.StringVar(&s.Profile, "profile", "", "Read sso_session, sso_start_url, sso_region, sso_account_id and sso_role_name from this profile of the shared config file")
+	flags.StringVar(&s.StartURL, "start-url", "", "AWS access portal URL")
+	flags.StringVar(&s.Region, "sso-region", "", "Region of IAM Identity Center")
+	flags.StringVar(&s.Account, "account", "", "Account to get credentials for, picked from the assigned accounts when not set")
+	flags.StringVar(&s.Role, "role", "", "Name of the permission set role to get credentials for, picked from the assigned roles when not set")
+	flags.BoolVar(&s.Force, "force", false, "Log in again even if a cached access token is valid")
+
+	o := &options{}
+	flags.BoolVar(&o.Debug, "debug", false, "Debug")
+	flags.StringVar(&o.Region, "region", "", "AWS region exported with the credentials, taken from the profile or AWS_REGION when not set")
+	o.registerTransport(flags)
+	o.registerOutput(flags)
+
+	return noArgs(func() error {
+		setupLogging(o.Debug)
+
+		p, err := s.resolve()
+		if err != nil {
+			return err
+		}
+
+		ctx := context.Background()
+		cfg, err := o.regionConfig(ctx, p.Region)
+		if err != nil {
+			return err
+		}
+
+		token, err := s.token(ctx, cfg, p)
+		if err != nil {
+			return err
+		}
+
+		// let the user pick what is not set
+		if p.AccountID == "" || p.RoleName == "" {
+			c, err := pickSSORole(ctx, cfg, p, token)
+			if c == nil || err != nil {
+				return err
+			}
+			p.AccountID, p.RoleName = c.AccountID, c.RoleName
+		}
+
+		credentials, err := ssoCredentials(ctx, cfg, token, p.AccountID, p.RoleName)
+		if err != nil {
+			return err
+		}
+		credentials.region = configuredRegion(o.Region, s.Profile)
+		return o.output(credentials)
+	})
+}
+
+// resolve combines the settings of -profile with the flags, flags taking
+// precedence.
+func (s *ssoOptions) resolve() (awsconfig.SSOProfile, error) {
+	p := awsconfig.SSOProfile{}
+	if s.Profile != "" {
+		path, err := awsconfig.ConfigPath()
+		if err != nil {
+			return p, err
+		}
+		f, err := awsconfig.Load(path)
+		if err != nil {
+			return p, err
+		}
+		if p, err = f.SSO(s.Profile); err != nil {
+			return p, err
+		}
+	}
+
+	if s.StartURL != "" {
+		// the token of another start URL must not be shared with the session
+		p.StartURL, p.Session = s.StartURL, ""
+	}
+	if s.Region != "" {
+		p.Region = s.Region
+	}
+	if s.Account != "" {
+		p.AccountID = s.Account
+	}
+	if s.Role != "" {
+		p.RoleName = s.Role
+	}
+
+	if p.StartURL == "" || p.Region == "" {
+		return p, withClass(errUsage, errors.New("-start-url and -sso-region, or -profile, are required"))
+	}
+	return p, nil
+}
+
+// token returns the cached access token of the profile, refreshing it or
+// logging in with the device authorization flow when needed.
+func (s *ssoOptions) token(ctx context.Context, cfg aws.Config, p awsconfig.SSOProfile) (*sso.Token, error) {
+	path, err := sso.CachePath(p.CacheKey())
+	if err != nil {
+		return nil, err
+	}
+
+	cached := loadSSOToken(path, p)
+	client := ssooidc.NewFromConfig(cfg)
+	if !s.Force {
+		if token := refreshSSOToken(ctx, client, path, cached); token != nil {
+			return token, nil
+		}
+	}
+
+	token, err := sso.Login(ctx, client, cached, p.StartURL, p.Region, func(a sso.Authorization) {
+		_, _ = fmt.Fprintf(os.Stderr, "Open %s in a browser and confirm the code %s\n", a.VerificationURIComplete, a.UserCode)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := sso.SaveToken(path, token); err != nil {
+		log.Info("Can not write SSO token cache: ", err)
+	}
+	return token, nil
+}
+
+// loadSSOToken reads the cached token of p, or returns nil when there is none.
+func loadSSOToken(path string, p awsconfig.SSOProfile) *sso.Token {
+	cached, err := sso.LoadToken(path)
+	if err != nil {
+		log.Info("Can not read cached SSO token: ", err)
+		return nil
+	}
+	if cached != nil && cached.StartURL != p.StartURL {
+		return nil
+	}
+	return cached
+}
+
+// refreshSSOToken returns cached while it is valid, or a refreshed token once
+// it expired. It returns nil when the user has to log in again.
+func refreshSSOToken(ctx context.Context, client sso.OIDC, path string, cached *sso.Token) *sso.Token {
+	if cached == nil {
+		return nil
+	}
+	if cached.Valid(ssoTokenWindow) {
+		log.Debug("Using cached SSO token expiring at ", cached.ExpiresAt)
+		return cached
+	}
+
+	token, err := sso.Refresh(ctx, client, cached)
+	if err != nil {
+		log.Debug("Can not refresh SSO token: ", err)
+		return nil
+	}
+	if err := sso.SaveToken(path, token); err != nil {
+		log.Info("Can not write SSO token cache: ", err)
+	}
+	return token
+}
+
+// ssoCredentials gets role credentials for an account with an access token.
+func ssoCredentials(ctx context.Context, cfg aws.Config, token *sso.Token, account, role string) (*credentials, error) {
+	result, err := ssoapi.NewFromConfig(cfg).GetRoleCredentials(ctx, &ssoapi.GetRoleCredentialsInput{
+		AccessToken: aws.String(token.AccessToken),
+		AccountId:   aws.String(account),
+		RoleName:    aws.String(role),
+	})
+	var notFound *ssotypes.ResourceNotFoundException
+	switch {
+	case errors.As(err, &notFound):
+		return nil, withClass(errAccessDenied, fmt.Errorf("%s in %s is not assigned to you: %w", role, account, err))
+	case err != nil:
+		// an expired or revoked access token is UnauthorizedException
+		class := classify(err)
+		if class == nil {
+			class = errNoCredentials
+		}
+		return nil, withClass(class, fmt.Errorf("can not get credentials of %s in %s, run with -force to log in again: %w", role, account, err))
+	}
+
+	c := result.RoleCredentials
+	credentials := new(credentials)
+	credentials.New(aws.ToString(c.AccessKeyId), aws.ToString(c.SecretAccessKey), aws.ToString(c.SessionToken), time.UnixMilli(c.Expiration))
+	return credentials, nil
+}
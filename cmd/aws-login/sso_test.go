@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+
+	"github.com/michalschott/aws-login/pkg/sso"
+)
+
+func TestSSOCredentials(t *testing.T) {
+	isolateConfig(t)
+	tests := []struct {
+		status  int
+		code    string
+		wantErr *errorClass
+	}{
+		{status: http.StatusOK},
+		{status: http.StatusUnauthorized, code: "UnauthorizedException", wantErr: errExpired},
+		{status: http.StatusNotFound, code: "ResourceNotFoundException", wantErr: errAccessDenied},
+		{status: http.StatusTooManyRequests, code: "TooManyRequestsException", wantErr: errThrottled},
+		{status: http.StatusBadRequest, code: "InvalidRequestException", wantErr: errNoCredentials},
+	}
+
+	for _, tt := range tests {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			if tt.code != "" {
+				w.Header().Set("X-Amzn-ErrorType", tt.code)
+				w.WriteHeader(tt.status)
+				_, _ = fmt.Fprintf(w, `{"message":"%s"}`, tt.code)
+				return
+			}
+			_, _ = fmt.Fprintf(w, `{"roleCredentials":{"accessKeyId":"ASIAFAKE","secretAccessKey":"secret","sessionToken":"token","expiration":%d}}`,
+				time.Now().Add(time.Hour).UnixMilli())
+		}))
+		t.Setenv("AWS_ENDPOINT_URL_SSO", srv.URL)
+
+		cfg, err := config.LoadDefaultConfig(context.Background(), config.WithRegion("eu-west-1"), config.WithRetryMaxAttempts(1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		credentials, err := ssoCredentials(context.Background(), cfg, &sso.Token{AccessToken: "access"}, "111111111111", "Admin")
+		if classify(err) != tt.wantErr || (tt.wantErr == nil) != (err == nil) {
+			t.Errorf("err is wrong, value=%v, wantErr=%v, err=%v", tt.code, tt.wantErr, err)
+		} else if err == nil && credentials.awsAccessKeyId != "ASIAFAKE" {
+			t.Errorf("got credentials %s", credentials.awsAccessKeyId)
+		}
+		srv.Close()
+	}
+}
+
+func TestSSOProxy(t *testing.T) {
+	isolateConfig(t)
+	var target string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// a proxy gets the absolute URL of the target
+		target = r.URL.Host
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(w, `{"roleCredentials":{"accessKeyId":"ASIAFAKE","secretAccessKey":"secret","sessionToken":"token","expiration":%d}}`,
+			time.Now().Add(time.Hour).UnixMilli())
+	}))
+	defer proxy.Close()
+
+	// the portal can not be resolved, only the proxy reaches it
+	t.Setenv("AWS_ENDPOINT_URL_SSO", "http://portal.example.invalid")
+	n := &network{Proxy: proxy.URL}
+	cfg, err := n.regionConfig(context.Background(), "eu-west-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ssoCredentials(context.Background(), cfg, &sso.Token{AccessToken: "access"}, "111111111111", "Admin"); err != nil {
+		t.Fatal(err)
+	}
+	if target != "portal.example.invalid" {
+		t.Errorf("proxy got a request for %q", target)
+	}
+}
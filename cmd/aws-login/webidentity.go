@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+
+	"github.com/michalschott/aws-login/pkg/format"
+	"github.com/michalschott/aws-login/pkg/login"
+	"github.com/michalschott/aws-login/pkg/webidentity"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// webIdentityOptions are the flags of the web-identity command choosing the
+// token source.
+type webIdentityOptions struct {
+	TokenFile    string
+	TokenEnv     string
+	TokenCommand string
+	Audience     string
+}
+
+func webIdentityCommand(flags *flag.FlagSet) func([]string) error {
+	w := &webIdentityOptions{}
+	flags.StringVar(&w.TokenFile, "token-file", "", "Read the OIDC token from this `file`")
+	flags.StringVar(&w.TokenEnv, "token-env", "", "Read the OIDC token from this environment `variable`")
+	flags.StringVar(&w.TokenCommand, "token-command", "", "Run this shell `command` and read the OIDC token from its output")
+	flags.StringVar(&w.Audience, "audience", webidentity.DefaultAudience, "Audience of the token requested from GitHub Actions")
+
+	o := &options{}
+	flags.BoolVar(&o.Debug, "debug", false, "Debug")
+	flags.StringVar(&o.Role, "role", "", "Role to assume, as ARN or ACCOUNT:NAME, taken from AWS_ROLE_ARN when not set")
+	flags.StringVar(&o.RoleSessionName, "session-name", "", "Session name, taken from AWS_ROLE_SESSION_NAME or generated when not set")
+	o.Duration = durations{3600}
+	flags.Var(&o.Duration, "duration", "Session duration in `seconds`")
+	flags.StringVar(&o.Region, "region", "", "AWS region, taken from AWS_REGION when not set")
+	o.registerNetwork(flags)
+	o.registerPolicy(flags)
+	o.registerOutput(flags)
+
+	return noArgs(func() error {
+		setupLogging(o.Debug)
+		if _, err := format.Get(o.Format); err != nil {
+			return err
+		}
+
+		ctx := context.Background()
+		cfg, err := o.config(ctx)
+		if err != nil {
+			return err
+		}
+		credentials, err := o.webIdentity(ctx, cfg, w)
+		if err != nil {
+			return err
+		}
+		return o.output(credentials)
+	})
+}
+
+// source returns the token source of the flags, or the one detected in the
+// environment.
+func (w *webIdentityOptions) source(client webidentity.HTTPClient) (webidentity.Source, error) {
+	sources := []webidentity.Source{}
+	if w.TokenFile != "" {
+		sources = append(sources, webidentity.File(w.TokenFile))
+	}
+	if w.TokenEnv != "" {
+		sources = append(sources, webidentity.Env(w.TokenEnv))
+	}
+	if w.TokenCommand != "" {
+		sources = append(sources, webidentity.Command(w.TokenCommand))
+	}
+
+	switch len(sources) {
+	case 0:
+		source, err := webidentity.Detect(os.Getenv, os.Environ(), client, w.Audience)
+		if err != nil {
+			return source, withClass(errNoCredentials, err)
+		}
+		return source, nil
+	case 1:
+		return sources[0], nil
+	}
+	return webidentity.Source{}, withClass(errUsage, errors.New("-token-file, -token-env and -token-command can not be used together"))
+}
+
+// webIdentity assumes the role with an OIDC token. No base credentials are
+// needed and sessions are not cached, CI jobs get a new token every run.
+func (o *options) webIdentity(ctx context.Context, cfg aws.Config, w *webIdentityOptions) (*credentials, error) {
+	role := o.Role
+	if role == "" {
+		role = os.Getenv("AWS_ROLE_ARN")
+	}
+	switch {
+	case role == "":
+		return nil, withClass(errUsage, errors.New("-role or AWS_ROLE_ARN is required"))
+	case strings.Contains(role, ","):
+		return nil, withClass(errUsage, errors.New("web identity can assume a single role only"))
+	case !strings.Contains(role, ":"):
+		// without credentials the account of the caller is not known
+		return nil, withClass(errUsage, errors.New("role has to be given as ARN or ACCOUNT:NAME"))
+	}
+	name := o.RoleSessionName
+	if name == "" {
+		name = os.Getenv("AWS_ROLE_SESSION_NAME")
+	}
+	chain, err := roleChain(role, name, o.Duration, partition(cfg.Region, ""), "")
+	if err != nil {
+		return nil, err
+	}
+	sessionPolicy, err := o.sessionPolicy(partition(cfg.Region, ""))
+	if err != nil {
+		return nil, err
+	}
+	if err := applyPolicy(chain, sessionPolicy); err != nil {
+		return nil, err
+	}
+
+	source, err := w.source(cfg.HTTPClient)
+	if err != nil {
+		return nil, err
+	}
+	log.Debugf("Reading the OIDC token from %s.", source.Name)
+	token, err := source.Token(ctx)
+	if err != nil {
+		return nil, withClass(errNoCredentials, err)
+	}
+
+	client := sts.NewFromConfig(cfg, o.stsOptions, func(so *sts.Options) {
+		so.Credentials = aws.AnonymousCredentials{}
+	})
+	result, err := login.WebIdentity(ctx, client, chain[0], token)
+	if err != nil {
+		return nil, err
+	}
+
+	credentials := new(credentials)
+	credentials.New(result.AccessKeyID, result.SecretAccessKey, result.SessionToken, result.Expiration)
+	credentials.region = o.region
+	return credentials, nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+func TestWebIdentitySource(t *testing.T) {
+	tests := []struct {
+		value   webIdentityOptions
+		want    string
+		wantErr *errorClass
+	}{
+		{value: webIdentityOptions{TokenFile: "/var/run/token"}, want: "token file /var/run/token"},
+		{value: webIdentityOptions{TokenEnv: "AWS_ID_TOKEN"}, want: "environment variable AWS_ID_TOKEN"},
+		{value: webIdentityOptions{TokenFile: "/var/run/token", TokenCommand: "get-token"}, wantErr: errUsage},
+	}
+
+	for _, tt := range tests {
+		got, err := tt.value.source(http.DefaultClient)
+		if classify(err) != tt.wantErr || (tt.wantErr != nil) != (err != nil) {
+			t.Errorf("err is wrong, value=%+v, wantErr=%v, err=%v", tt.value, tt.wantErr, err)
+			continue
+		}
+		if got.Name != tt.want {
+			t.Errorf("%+v: got source %q, expected %q", tt.value, got.Name, tt.want)
+		}
+	}
+}
+
+func TestWebIdentityRole(t *testing.T) {
+	t.Setenv("AWS_ROLE_ARN", "")
+	for _, role := range []string{"", "deploy", "111111111111:a,111111111111:b"} {
+		o := &options{Role: role, Duration: durations{3600}}
+		_, err := o.webIdentity(context.Background(), aws.Config{Region: "us-east-1"}, &webIdentityOptions{})
+		if classify(err) != errUsage {
+			t.Errorf("role %q: expected a usage error, got %v", role, err)
+		}
+	}
+}
+
+func TestWebIdentityGitHubActions(t *testing.T) {
+	isolateConfig(t)
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+	t.Setenv("AWS_ROLE_ARN", "")
+	t.Setenv("AWS_ROLE_SESSION_NAME", "")
+	t.Setenv("AWS_WEB_IDENTITY_TOKEN_FILE", "")
+
+	payload := fmt.Sprintf(`{"sub":"repo:example/app:ref:refs/heads/main","exp":%d}`, time.Now().Add(time.Hour).Unix())
+	token := "eyJhbGciOiJSUzI1NiJ9." + base64.RawURLEncoding.EncodeToString([]byte(payload)) + ".c2ln"
+	issuer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer request-token" || r.URL.Query().Get("audience") != "sts.amazonaws.com" {
+			http.Error(w, "bad token request", http.StatusForbidden)
+			return
+		}
+		_, _ = fmt.Fprintf(w, `{"value":%q}`, token)
+	}))
+	defer issuer.Close()
+	t.Setenv("ACTIONS_ID_TOKEN_REQUEST_URL", issuer.URL+"/token?api-version=2.0")
+	t.Setenv("ACTIONS_ID_TOKEN_REQUEST_TOKEN", "request-token")
+
+	var got string
+	stsSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil || r.Header.Get("Authorization") != "" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		got = r.PostForm.Get("WebIdentityToken")
+		w.Header().Set("Content-Type", "text/xml")
+		_, _ = fmt.Fprintf(w, `<AssumeRoleWithWebIdentityResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/"><AssumeRoleWithWebIdentityResult>`+
+			`<Credentials><AccessKeyId>ASIAFAKE</AccessKeyId><SecretAccessKey>secret</SecretAccessKey><SessionToken>token</SessionToken>`+
+			`<Expiration>%s</Expiration></Credentials></AssumeRoleWithWebIdentityResult></AssumeRoleWithWebIdentityResponse>`,
+			time.Now().Add(time.Hour).UTC().Format(time.RFC3339))
+	}))
+	defer stsSrv.Close()
+
+	o := &options{Role: "111111111111:deploy", Duration: durations{3600}, Region: "eu-west-1", network: network{EndpointURL: stsSrv.URL}}
+	cfg, err := o.config(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	credentials, err := o.webIdentity(context.Background(), cfg, &webIdentityOptions{Audience: "sts.amazonaws.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != token || credentials.awsAccessKeyId != "ASIAFAKE" {
+		t.Errorf("got credentials %s for token %q", credentials.awsAccessKeyId, got)
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+
+	"github.com/michalschott/aws-login/pkg/cache"
+	"github.com/michalschott/aws-login/pkg/identity"
+	"github.com/michalschott/aws-login/pkg/login"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// whoamiReport describes the credentials currently in use.
+type whoamiReport struct {
+	login.Identity
+	Principal  identity.Principal `json:"principal"`
+	Profile    string             `json:"profile,omitempty"`
+	Source     string             `json:"source"`
+	Expiration *time.Time         `json:"expiration,omitempty"`
+	Remaining  string             `json:"remaining,omitempty"`
+	Mfa        *bool              `json:"mfa,omitempty"`
+}
+
+func whoamiCommand(flags *flag.FlagSet) func([]string) error {
+	Debug := flags.Bool("debug", false, "Debug")
+	Profile := flags.String("profile", "", "Report on this profile instead of AWS_PROFILE")
+	Output := flags.String("o", "text", "Output format, one of: text, json")
+	o := &options{}
+	o.registerNetwork(flags)
+
+	return noArgs(func() error {
+		setupLogging(*Debug)
+		if *Output != "text" && *Output != "json" {
+			return withClass(errUsage, fmt.Errorf("unknown output format %q, expected text or json", *Output))
+		}
+		if *Profile != "" {
+			if err := os.Setenv("AWS_PROFILE", *Profile); err != nil {
+				return err
+			}
+		}
+
+		ctx := context.Background()
+		cfg, err := o.config(ctx)
+		if err != nil {
+			return err
+		}
+		report, err := whoami(ctx, cfg, o.stsOptions)
+		if err != nil {
+			return err
+		}
+
+		if *Output == "json" {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(report)
+		}
+		return report.print()
+	})
+}
+
+func whoami(ctx context.Context, cfg aws.Config, optFns ...func(*sts.Options)) (*whoamiReport, error) {
+	if cfg.Credentials == nil {
+		return nil, withClass(errNoCredentials, fmt.Errorf("no credentials found"))
+	}
+	creds, err := cfg.Credentials.Retrieve(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := login.CallerIdentity(ctx, sts.NewFromConfig(cfg, optFns...))
+	if err != nil {
+		return nil, err
+	}
+	principal, err := identity.Parse(c.Arn)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &whoamiReport{Identity: *c, Principal: principal, Source: creds.Source}
+	if !strings.HasPrefix(creds.Source, "EnvConfigCredentials") {
+		report.Profile = os.Getenv("AWS_PROFILE")
+		if report.Profile == "" {
+			report.Profile = "default"
+		}
+	}
+
+	// sessions obtained by aws-login are found in the cache, which also
+	// records whether MFA was used
+	entry := cachedSession(creds.AccessKeyID)
+	switch {
+	case entry != nil:
+		report.Expiration = &entry.Expiration
+		mfa := entry.Key.MfaSerial != ""
+		report.Mfa = &mfa
+	case creds.CanExpire:
+		report.Expiration = &creds.Expires
+	default:
+		if t, err := time.Parse(time.RFC3339, os.Getenv("AWS_CREDENTIAL_EXPIRATION")); err == nil {
+			report.Expiration = &t
+		}
+	}
+	if report.Mfa == nil && strings.HasPrefix(creds.AccessKeyID, "AKIA") {
+		// long term access keys are never authenticated with MFA
+		mfa := false
+		report.Mfa = &mfa
+	}
+	if report.Expiration != nil {
+		report.Remaining = time.Until(*report.Expiration).Round(time.Second).String()
+	}
+	return report, nil
+}
+
+// cachedSession returns the cache entry holding accessKeyID, if any.
+func cachedSession(accessKeyID string) *cache.Entry {
+	sessionCache, err := cache.New()
+	if err != nil {
+		return nil
+	}
+	entries, err := sessionCache.List()
+	if err != nil {
+		log.Debug("Can not read the session cache: ", err)
+		return nil
+	}
+	for _, e := range entries {
+		if e.AccessKeyID == accessKeyID {
+			return &e
+		}
+	}
+	return nil
+}
+
+func (r *whoamiReport) print() error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	line := func(name, value string) {
+		if value != "" {
+			_, _ = fmt.Fprintf(w, "%s:\t%s\n", name, value)
+		}
+	}
+
+	line("Account", r.Account)
+	line("ARN", r.Arn)
+	line("User ID", r.UserID)
+	line("Partition", r.Principal.Partition)
+	line("Type", r.Principal.Type)
+	if r.Principal.Type == identity.TypeAssumedRole {
+		line("Role", r.Principal.RoleName)
+		line("Session", r.Principal.SessionName)
+	} else {
+		line("Name", r.Principal.Name)
+		line("Path", r.Principal.Path)
+	}
+	line("Profile", r.Profile)
+	line("Source", r.Source)
+
+	expires := "unknown"
+	if r.Expiration != nil {
+		expires = r.Expiration.Local().Format(time.RFC3339)
+		if time.Now().After(*r.Expiration) {
+			expires += " (expired)"
+		} else {
+			expires += " (in " + r.Remaining + ")"
+		}
+	}
+	line("Expires", expires)
+
+	mfa := "unknown"
+	if r.Mfa != nil {
+		mfa = "no"
+		if *r.Mfa {
+			mfa = "yes"
+		}
+	}
+	line("MFA", mfa)
+	return w.Flush()
+}
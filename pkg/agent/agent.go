@@ -0,0 +1,226 @@
+package agent
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/michalschott/aws-login/pkg/format"
+)
+
+// Operations understood by the agent.
+const (
+	OpCredentials = "credentials"
+	OpList        = "list"
+	OpRevoke      = "revoke"
+)
+
+// ClassMismatch is the error class of credential requests the agent refuses
+// because the client uses other base credentials. Clients log in directly
+// instead.
+const ClassMismatch = "mismatch"
+
+// Request is a single JSON line sent to the agent.
+type Request struct {
+	Op string `json:"op"`
+
+	// base settings of the client, which have to match the ones the agent
+	// was started with
+	BaseProfile string `json:"baseProfile,omitempty"`
+	Region      string `json:"region,omitempty"`
+	MfaSerial   string `json:"mfaSerial,omitempty"`
+
+	// role settings, as the flags of the same name; without them the base
+	// session is used
+	Role        string `json:"role,omitempty"`
+	Profile     string `json:"profile,omitempty"`
+	Account     string `json:"account,omitempty"`
+	SessionName string `json:"sessionName,omitempty"`
+	Duration    []int  `json:"duration,omitempty"`
+
+	// session settings, as the flags of the same name; tags are KEY=VALUE
+	Tags           []string `json:"tags,omitempty"`
+	TransitiveTags []string `json:"transitiveTags,omitempty"`
+	SourceIdentity string   `json:"sourceIdentity,omitempty"`
+	ExternalID     string   `json:"externalId,omitempty"`
+
+	// session policy, the document and managed policy ARNs
+	Policy     string   `json:"policy,omitempty"`
+	PolicyArns []string `json:"policyArns,omitempty"`
+}
+
+// Credentials are temporary credentials handed out by the agent.
+type Credentials struct {
+	AccessKeyID     string    `json:"accessKeyId"`
+	SecretAccessKey string    `json:"secretAccessKey"`
+	SessionToken    string    `json:"sessionToken"`
+	Expiration      time.Time `json:"expiration"`
+	Region          string    `json:"region,omitempty"`
+}
+
+// Format converts c for output by a formatter.
+func (c Credentials) Format() format.Credentials {
+	return format.Credentials(c)
+}
+
+// Session describes a session held by the agent.
+type Session struct {
+	Role       string    `json:"role,omitempty"`
+	Profile    string    `json:"profile,omitempty"`
+	Expiration time.Time `json:"expiration"`
+}
+
+// Response is the JSON line the agent answers a request with.
+type Response struct {
+	Error       string       `json:"error,omitempty"`
+	ErrorClass  string       `json:"errorClass,omitempty"`
+	Credentials *Credentials `json:"credentials,omitempty"`
+	Sessions    []Session    `json:"sessions,omitempty"`
+	Revoked     int          `json:"revoked,omitempty"`
+}
+
+// SocketPath returns the socket of the agent: AWS_LOGIN_AGENT_SOCK, or
+// aws-login/agent.sock in XDG_RUNTIME_DIR or the user cache directory.
+func SocketPath() (string, error) {
+	if path := os.Getenv("AWS_LOGIN_AGENT_SOCK"); path != "" {
+		return path, nil
+	}
+
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		var err error
+		if dir, err = os.UserCacheDir(); err != nil {
+			return "", err
+		}
+	}
+	return filepath.Join(dir, "aws-login", "agent.sock"), nil
+}
+
+// Listen creates the socket at path, readable only by the current user. Its
+// directory has to be private to the user as well. A socket left behind by an
+// agent that is gone is replaced.
+func Listen(path string) (net.Listener, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	// the directory may have existed with other permissions
+	if err := checkDir(filepath.Dir(path)); err != nil {
+		return nil, err
+	}
+
+	if conn, err := net.Dial("unix", path); err == nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("an agent is already listening on %s", path)
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	listener, err := listenUnix(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		_ = listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
+
+// Serve answers requests on listener with handle until it is closed. Every
+// connection may send any number of requests, one JSON document per line.
+func Serve(listener net.Listener, handle func(ctx context.Context, r Request) Response) error {
+	for {
+		conn, err := listener.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		go serveConn(conn, handle)
+	}
+}
+
+func serveConn(conn net.Conn, handle func(ctx context.Context, r Request) Response) {
+	defer func() { _ = conn.Close() }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	scanner := bufio.NewScanner(conn)
+	encoder := json.NewEncoder(conn)
+	for scanner.Scan() {
+		var r Request
+		response := Response{}
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			response.Error = "invalid request: " + err.Error()
+		} else {
+			response = handle(ctx, r)
+		}
+
+		if err := encoder.Encode(response); err != nil {
+			return
+		}
+	}
+}
+
+// Client talks to a running agent.
+type Client struct {
+	conn    net.Conn
+	scanner *bufio.Scanner
+}
+
+// Dial connects to the agent listening on path.
+func Dial(path string) (*Client, error) {
+	conn, err := net.DialTimeout("unix", path, time.Second)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{conn: conn, scanner: bufio.NewScanner(conn)}, nil
+}
+
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// Do sends a request and waits for the response. Errors reported by the agent
+// are returned as error.
+func (c *Client) Do(r Request) (*Response, error) {
+	if err := json.NewEncoder(c.conn).Encode(r); err != nil {
+		return nil, err
+	}
+
+	if !c.scanner.Scan() {
+		if err := c.scanner.Err(); err != nil {
+			return nil, err
+		}
+		return nil, errors.New("agent closed the connection")
+	}
+
+	response := &Response{}
+	if err := json.Unmarshal(c.scanner.Bytes(), response); err != nil {
+		return nil, err
+	}
+	if response.Error != "" {
+		return nil, &Error{Message: response.Error, Class: response.ErrorClass}
+	}
+	return response, nil
+}
+
+// Error is an error reported by the agent.
+type Error struct {
+	Message string
+	// Class names the kind of failure, see Response.ErrorClass
+	Class string
+}
+
+func (e *Error) Error() string {
+	return "agent: " + e.Message
+}
@@ -0,0 +1,140 @@
+package agent
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestListenDirectory(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("permissions are ACLs on Windows")
+	}
+
+	// a missing directory is created private
+	path := filepath.Join(t.TempDir(), "aws-login", "agent.sock")
+	listener, err := Listen(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = listener.Close()
+	info, err := os.Stat(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0700 {
+		t.Errorf("got directory mode %v", info.Mode().Perm())
+	}
+
+	// an existing directory others can reach is refused
+	if err := os.Chmod(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if listener, err := Listen(path); err == nil {
+		_ = listener.Close()
+		t.Error("expected a directory accessible by others to be refused")
+	}
+
+	file := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(file, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if listener, err := Listen(filepath.Join(file, "agent.sock")); err == nil {
+		_ = listener.Close()
+		t.Error("expected a file as directory to be refused")
+	}
+}
+
+func TestAgent(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Chmod(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "agent.sock")
+
+	// a socket left behind is replaced
+	if err := os.WriteFile(path, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	listener, err := Listen(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = listener.Close() }()
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("got socket mode %v", info.Mode().Perm())
+	}
+
+	if _, err := Listen(path); err == nil {
+		t.Error("second agent on the same socket should fail")
+	}
+
+	expiration := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	go func() {
+		_ = Serve(listener, func(ctx context.Context, r Request) Response {
+			switch r.Op {
+			case OpCredentials:
+				if r.Role == "" {
+					return Response{Error: "no role"}
+				}
+				return Response{Credentials: &Credentials{AccessKeyID: "ASIA", Expiration: expiration}}
+			case OpList:
+				return Response{Sessions: []Session{{Role: "Admin", Expiration: expiration}}}
+			default:
+				return Response{Error: "unknown op"}
+			}
+		})
+	}()
+
+	client, err := Dial(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Close() }()
+
+	tests := []struct {
+		request Request
+		wantErr bool
+	}{
+		{request: Request{Op: OpCredentials, Role: "Admin"}},
+		{request: Request{Op: OpCredentials}, wantErr: true},
+		{request: Request{Op: OpList}},
+		{request: Request{Op: "stop"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		response, err := client.Do(tt.request)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("err is wrong, value=%v, wantErr=%v, err=%v", tt.request, tt.wantErr, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+
+		switch tt.request.Op {
+		case OpCredentials:
+			if response.Credentials == nil || response.Credentials.AccessKeyID != "ASIA" || !response.Credentials.Expiration.Equal(expiration) {
+				t.Errorf("got %+v", response)
+			}
+		case OpList:
+			if len(response.Sessions) != 1 || response.Sessions[0].Role != "Admin" {
+				t.Errorf("got %+v", response)
+			}
+		}
+	}
+
+	_ = listener.Close()
+	if _, err := Dial(path); err == nil {
+		t.Error("dial after close should fail")
+	}
+}
@@ -0,0 +1,27 @@
+//go:build !unix
+
+package agent
+
+import (
+	"fmt"
+	"net"
+	"os"
+)
+
+// listenUnix creates the socket at path. Windows has no umask, access is
+// governed by the ACLs inherited from the directory.
+func listenUnix(path string) (net.Listener, error) {
+	return net.Listen("unix", path)
+}
+
+// checkDir checks that dir is a directory. Its ACLs are not inspected.
+func checkDir(dir string) error {
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+	return nil
+}
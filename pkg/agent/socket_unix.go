@@ -0,0 +1,43 @@
+//go:build unix
+
+package agent
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"syscall"
+)
+
+// umaskMu serializes changes of the process wide umask.
+var umaskMu sync.Mutex
+
+// listenUnix creates the socket at path with the umask 077, so it is never
+// accessible by other users, not even until it is chmodded.
+func listenUnix(path string) (net.Listener, error) {
+	umaskMu.Lock()
+	defer umaskMu.Unlock()
+	old := syscall.Umask(0o077)
+	defer syscall.Umask(old)
+	return net.Listen("unix", path)
+}
+
+// checkDir checks that dir is a directory owned by the current user and not
+// accessible by anyone else, as others could otherwise replace the socket.
+func checkDir(dir string) error {
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok && int(stat.Uid) != os.Getuid() {
+		return fmt.Errorf("%s is owned by another user, choose another socket", dir)
+	}
+	if info.Mode().Perm()&0o077 != 0 {
+		return fmt.Errorf("%s is accessible by other users, restrict it with chmod 700 or choose another socket", dir)
+	}
+	return nil
+}
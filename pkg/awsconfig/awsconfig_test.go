@@ -0,0 +1,289 @@
+package awsconfig
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+const credentialsFile = `# managed by hand
+[default]
+aws_access_key_id = AKIADEFAULT
+aws_secret_access_key = secret ; not a comment
+
+; work account
+[work]
+aws_access_key_id=AKIAOLD
+# aws-login: expires 2000-01-01T00:00:00Z
+aws_session_token = old
+
+[other]
+region = eu-west-1
+s3 =
+  aws_access_key_id = nested
+`
+
+func TestParseRoundTrip(t *testing.T) {
+	f, err := Parse(strings.NewReader(credentialsFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var b strings.Builder
+	if _, err := f.WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != credentialsFile {
+		t.Errorf("got %q but expected %q", b.String(), credentialsFile)
+	}
+
+	if got := f.Sections(); strings.Join(got, ",") != "default,work,other" {
+		t.Errorf("got sections %v", got)
+	}
+	if v, _ := f.Get("default", "aws_secret_access_key"); v != "secret ; not a comment" {
+		t.Errorf("got value %q", v)
+	}
+	if _, ok := f.Get("other", "aws_access_key_id"); ok {
+		t.Error("nested key should not be returned")
+	}
+}
+
+func TestWriteCredentials(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials")
+	if err := os.WriteFile(path, []byte(credentialsFile), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	expiration := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := WriteCredentials(path, "work", "ASIANEW", "newsecret", "newtoken", expiration); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteCredentials(path, "new", "ASIA2", "secret2", "token2", expiration); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `# managed by hand
+[default]
+aws_access_key_id = AKIADEFAULT
+aws_secret_access_key = secret ; not a comment
+
+; work account
+[work]
+aws_access_key_id = ASIANEW
+aws_session_token = newtoken
+aws_secret_access_key = newsecret
+# aws-login: expires 2030-01-02T03:04:05Z
+
+[other]
+region = eu-west-1
+s3 =
+  aws_access_key_id = nested
+
+[new]
+aws_access_key_id = ASIA2
+aws_secret_access_key = secret2
+aws_session_token = token2
+# aws-login: expires 2030-01-02T03:04:05Z
+`
+	if string(got) != want {
+		t.Errorf("got\n%s\nbut expected\n%s", got, want)
+	}
+
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != 0600 {
+			t.Errorf("got permissions %v", info.Mode().Perm())
+		}
+	}
+
+	if _, err := os.Stat(path + ".lock"); !os.IsNotExist(err) {
+		t.Errorf("lock file was not removed: %v", err)
+	}
+}
+
+func TestUpdateLocked(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials")
+	if err := os.WriteFile(path+".lock", nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	timeout := LockTimeout
+	LockTimeout = 200 * time.Millisecond
+	defer func() { LockTimeout = timeout }()
+
+	err := Update(path, func(*File) error { return nil })
+	if err == nil {
+		t.Error("expected lock timeout")
+	}
+}
+
+const configFile = `[default]
+region = eu-west-1
+
+[profile bastion]
+role_arn = arn:aws:iam::111111111111:role/bastion
+source_profile = default
+mfa_serial = arn:aws:iam::111111111111:mfa/alice
+duration_seconds = 7200
+
+[profile workload]
+role_arn = arn:aws:iam::222222222222:role/admin
+source_profile = bastion
+role_session_name = alice
+external_id = secret
+session_tags = team=platform
+transitive_session_tags = project=login
+source_identity = alice
+
+[profile static]
+source_profile = nowhere
+
+[profile loop-a]
+role_arn = arn:aws:iam::111111111111:role/a
+source_profile = loop-b
+
+[profile loop-b]
+role_arn = arn:aws:iam::111111111111:role/b
+source_profile = loop-a
+
+[profile ec2]
+role_arn = arn:aws:iam::111111111111:role/a
+credential_source = Ec2InstanceMetadata
+
+[profile broken]
+role_arn = arn:aws:iam::111111111111:role/a
+source_profile = default
+duration_seconds = 1h
+`
+
+func TestResolveRole(t *testing.T) {
+	f, err := Parse(strings.NewReader(configFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		profile    string
+		wantSource string
+		wantChain  []RoleProfile
+		wantErr    bool
+	}{
+		{
+			profile:    "workload",
+			wantSource: "default",
+			wantChain: []RoleProfile{
+				{Name: "bastion", RoleArn: "arn:aws:iam::111111111111:role/bastion", MfaSerial: "arn:aws:iam::111111111111:mfa/alice", DurationSeconds: 7200},
+				{Name: "workload", RoleArn: "arn:aws:iam::222222222222:role/admin", RoleSessionName: "alice", ExternalID: "secret",
+					SessionTags: "team=platform", TransitiveSessionTags: "project=login", SourceIdentity: "alice"},
+			},
+		},
+		{profile: "static", wantSource: "static", wantChain: []RoleProfile{}},
+		{profile: "only-in-credentials", wantSource: "only-in-credentials", wantChain: []RoleProfile{}},
+		{profile: "loop-a", wantErr: true},
+		{profile: "ec2", wantErr: true},
+		{profile: "broken", wantErr: true},
+	}
+
+	for _, test := range tests {
+		source, chain, err := f.ResolveRole(test.profile)
+		if (test.wantErr && err == nil) || !test.wantErr && err != nil {
+			t.Errorf("err is wrong, profile=%v, wantErr=%v, err=%v", test.profile, test.wantErr, err)
+			continue
+		}
+		if source != test.wantSource || !reflect.DeepEqual(chain, test.wantChain) {
+			t.Errorf("profile=%v: got %v, %+v, expected %v, %+v", test.profile, source, chain, test.wantSource, test.wantChain)
+		}
+	}
+}
+
+const ssoConfigFile = `[profile dev]
+sso_session = corp
+sso_account_id = 111111111111
+sso_role_name = Admin
+
+[profile legacy]
+sso_start_url = https://legacy.awsapps.com/start
+sso_region = us-east-1
+
+[profile missing-session]
+sso_session = nowhere
+
+[profile plain]
+region = eu-west-1
+
+[sso-session corp]
+sso_start_url = https://corp.awsapps.com/start
+sso_region = eu-west-1
+`
+
+func TestSSO(t *testing.T) {
+	f, err := Parse(strings.NewReader(ssoConfigFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		profile      string
+		want         SSOProfile
+		wantCacheKey string
+		wantErr      bool
+	}{
+		{
+			profile:      "dev",
+			want:         SSOProfile{Name: "dev", StartURL: "https://corp.awsapps.com/start", Region: "eu-west-1", AccountID: "111111111111", RoleName: "Admin", Session: "corp"},
+			wantCacheKey: "corp",
+		},
+		{
+			profile:      "legacy",
+			want:         SSOProfile{Name: "legacy", StartURL: "https://legacy.awsapps.com/start", Region: "us-east-1"},
+			wantCacheKey: "https://legacy.awsapps.com/start",
+		},
+		{profile: "missing-session", wantErr: true},
+		{profile: "plain", wantErr: true},
+		{profile: "unknown", wantErr: true},
+	}
+
+	for _, test := range tests {
+		got, err := f.SSO(test.profile)
+		if (test.wantErr && err == nil) || !test.wantErr && err != nil {
+			t.Errorf("err is wrong, profile=%v, wantErr=%v, err=%v", test.profile, test.wantErr, err)
+			continue
+		}
+		if got != test.want || (!test.wantErr && got.CacheKey() != test.wantCacheKey) {
+			t.Errorf("profile=%v: got %+v, expected %+v", test.profile, got, test.want)
+		}
+	}
+}
+
+func TestProfiles(t *testing.T) {
+	f, err := Parse(strings.NewReader(configFile + "\n" + ssoConfigFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "default,bastion,workload,static,loop-a,loop-b,ec2,broken,dev,legacy,missing-session,plain"
+	if got := strings.Join(f.Profiles(), ","); got != expected {
+		t.Errorf("got %s but expected %s", got, expected)
+	}
+
+	sessions := f.SSOSessions()
+	expectedSessions := []SSOProfile{
+		{StartURL: "https://corp.awsapps.com/start", Region: "eu-west-1", Session: "corp"},
+		{StartURL: "https://legacy.awsapps.com/start", Region: "us-east-1"},
+	}
+	if !reflect.DeepEqual(sessions, expectedSessions) {
+		t.Errorf("got %+v but expected %+v", sessions, expectedSessions)
+	}
+}
@@ -0,0 +1,21 @@
+package awsconfig
+
+import (
+	"time"
+)
+
+const expirationComment = "aws-login: expires "
+
+// WriteCredentials upserts temporary credentials into the named profile of
+// the shared credentials file at path. Other profiles are left untouched.
+func WriteCredentials(path, profile, accessKeyID, secretAccessKey, sessionToken string, expiration time.Time) error {
+	return Update(path, func(f *File) error {
+		f.Set(profile, "aws_access_key_id", accessKeyID)
+		f.Set(profile, "aws_secret_access_key", secretAccessKey)
+		f.Set(profile, "aws_session_token", sessionToken)
+		if !expiration.IsZero() {
+			f.SetComment(profile, expirationComment, expiration.UTC().Format(time.RFC3339))
+		}
+		return nil
+	})
+}
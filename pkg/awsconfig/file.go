@@ -0,0 +1,137 @@
+package awsconfig
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var (
+	// LockTimeout is how long Update waits for another writer to finish.
+	LockTimeout = 10 * time.Second
+	// staleLock is the age after which a lock file is assumed to be left
+	// behind by a crashed process and removed.
+	staleLock = time.Minute
+)
+
+// CredentialsPath returns the location of the shared credentials file.
+func CredentialsPath() (string, error) {
+	return path("AWS_SHARED_CREDENTIALS_FILE", "credentials")
+}
+
+// ConfigPath returns the location of the shared config file.
+func ConfigPath() (string, error) {
+	return path("AWS_CONFIG_FILE", "config")
+}
+
+func path(env, name string) (string, error) {
+	if p := os.Getenv(env); p != "" {
+		return p, nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".aws", name), nil
+}
+
+// Load reads the file at path. A missing file is treated as an empty one.
+func Load(path string) (*File, error) {
+	fd, err := os.Open(filepath.Clean(path))
+	if errors.Is(err, os.ErrNotExist) {
+		return &File{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = fd.Close() }()
+
+	return Parse(fd)
+}
+
+// Update loads the file at path, applies fn and writes the result back
+// atomically while holding a lock file. The file is written with 0600
+// permissions.
+func Update(path string, fn func(*File) error) error {
+	// write through symlinks instead of replacing them
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	unlock, err := lock(path + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	f, err := Load(path)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(f); err != nil {
+		return err
+	}
+
+	return write(path, f)
+}
+
+func write(path string, f *File) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if err := tmp.Chmod(0600); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+
+	if _, err := f.WriteTo(tmp); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+func lock(path string) (func(), error) {
+	deadline := time.Now().Add(LockTimeout)
+	for {
+		fd, err := os.OpenFile(filepath.Clean(path), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			_ = fd.Close()
+			return func() { _ = os.Remove(path) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, err
+		}
+
+		if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > staleLock {
+			_ = os.Remove(path)
+			continue
+		}
+
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out waiting for lock %s", path)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
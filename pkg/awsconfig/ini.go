@@ -0,0 +1,205 @@
+package awsconfig
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// File is a shared config or credentials file. It is kept line by line so that
+// writing it back preserves comments, ordering and formatting of everything
+// that was not explicitly changed.
+type File struct {
+	preamble []string
+	sections []*section
+}
+
+type section struct {
+	name  string
+	lines []string
+}
+
+// Parse reads an INI document.
+func Parse(r io.Reader) (*File, error) {
+	f := &File{}
+	var current *section
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if name, ok := sectionName(line); ok {
+			current = &section{name: name, lines: []string{line}}
+			f.sections = append(f.sections, current)
+			continue
+		}
+
+		if current == nil {
+			f.preamble = append(f.preamble, line)
+		} else {
+			current.lines = append(current.lines, line)
+		}
+	}
+
+	return f, scanner.Err()
+}
+
+// WriteTo writes the document back in INI format.
+func (f *File) WriteTo(w io.Writer) (int64, error) {
+	var written int64
+	for _, line := range f.lines() {
+		n, err := io.WriteString(w, line+"\n")
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+func (f *File) lines() []string {
+	lines := append([]string{}, f.preamble...)
+	for _, s := range f.sections {
+		lines = append(lines, s.lines...)
+	}
+	return lines
+}
+
+// Sections returns names of all sections in order of appearance.
+func (f *File) Sections() []string {
+	names := make([]string, 0, len(f.sections))
+	for _, s := range f.sections {
+		names = append(names, s.name)
+	}
+	return names
+}
+
+// Get returns the value of key in the named section.
+func (f *File) Get(name, key string) (string, bool) {
+	s := f.section(name)
+	if s == nil {
+		return "", false
+	}
+
+	for _, line := range s.lines[1:] {
+		if k, v, ok := keyValue(line); ok && k == key {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// Keys returns all key/value pairs of the named section.
+func (f *File) Keys(name string) map[string]string {
+	s := f.section(name)
+	if s == nil {
+		return nil
+	}
+
+	keys := map[string]string{}
+	for _, line := range s.lines[1:] {
+		if k, v, ok := keyValue(line); ok {
+			keys[k] = v
+		}
+	}
+	return keys
+}
+
+// Set updates key in place or appends it to the named section, creating the
+// section when needed.
+func (f *File) Set(name, key, value string) {
+	s := f.ensureSection(name)
+	entry := key + " = " + value
+
+	for i, line := range s.lines[1:] {
+		if k, _, ok := keyValue(line); ok && k == key {
+			s.lines[i+1] = entry
+			return
+		}
+	}
+	s.insert(entry)
+}
+
+// SetComment replaces all comments in the named section starting with prefix
+// with a single comment made of prefix and text.
+func (f *File) SetComment(name, prefix, text string) {
+	s := f.ensureSection(name)
+	comment := "# " + prefix + text
+
+	lines := s.lines[:1]
+	for _, line := range s.lines[1:] {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "#") && strings.HasPrefix(strings.TrimSpace(trimmed[1:]), prefix) {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	s.lines = lines
+	s.insert(comment)
+}
+
+func (f *File) section(name string) *section {
+	for _, s := range f.sections {
+		if s.name == name {
+			return s
+		}
+	}
+	return nil
+}
+
+func (f *File) ensureSection(name string) *section {
+	if s := f.section(name); s != nil {
+		return s
+	}
+
+	// keep a blank line between the previous content and the new section
+	if lines := f.lines(); len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) != "" {
+		if len(f.sections) > 0 {
+			last := f.sections[len(f.sections)-1]
+			last.lines = append(last.lines, "")
+		} else {
+			f.preamble = append(f.preamble, "")
+		}
+	}
+
+	s := &section{name: name, lines: []string{"[" + name + "]"}}
+	f.sections = append(f.sections, s)
+	return s
+}
+
+// insert adds line after the last non-blank line of the section, so trailing
+// blank lines keep separating it from the next one.
+func (s *section) insert(line string) {
+	i := len(s.lines)
+	for i > 1 && strings.TrimSpace(s.lines[i-1]) == "" {
+		i--
+	}
+	s.lines = append(s.lines[:i], append([]string{line}, s.lines[i:]...)...)
+}
+
+func sectionName(line string) (string, bool) {
+	trimmed := strings.TrimSpace(line)
+	if !strings.HasPrefix(trimmed, "[") {
+		return "", false
+	}
+
+	end := strings.Index(trimmed, "]")
+	if end < 0 {
+		return "", false
+	}
+	return strings.Join(strings.Fields(trimmed[1:end]), " "), true
+}
+
+// keyValue parses a top level key. Indented lines belong to nested settings
+// such as s3 configuration and are ignored.
+func keyValue(line string) (string, string, bool) {
+	trimmed := strings.TrimSpace(line)
+	if trimmed == "" || trimmed[0] == '#' || trimmed[0] == ';' || line[0] == ' ' || line[0] == '\t' {
+		return "", "", false
+	}
+
+	key, value, ok := strings.Cut(trimmed, "=")
+	if !ok {
+		return "", "", false
+	}
+	return strings.TrimSpace(key), strings.TrimSpace(value), true
+}
@@ -0,0 +1,101 @@
+package awsconfig
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// RoleProfile holds the role settings of a profile in the shared config file.
+type RoleProfile struct {
+	Name            string
+	RoleArn         string
+	MfaSerial       string
+	DurationSeconds int
+	RoleSessionName string
+	ExternalID      string
+	// session tags as comma separated KEY=VALUE pairs
+	SessionTags           string
+	TransitiveSessionTags string
+	SourceIdentity        string
+}
+
+// ProfileSection returns the section name of a profile in the shared config
+// file.
+func ProfileSection(name string) string {
+	if name == "default" {
+		return name
+	}
+	return "profile " + name
+}
+
+// Profiles returns the names of all profiles in the shared config file in
+// order of appearance.
+func (f *File) Profiles() []string {
+	names := []string{}
+	for _, section := range f.Sections() {
+		if section == "default" {
+			names = append(names, section)
+		} else if name, ok := strings.CutPrefix(section, "profile "); ok {
+			names = append(names, strings.TrimSpace(name))
+		}
+	}
+	return names
+}
+
+// ResolveRole follows source_profile settings starting at the named profile
+// until it reaches a profile without role_arn. It returns the name of that
+// profile, which holds the base credentials, and the role profiles in the
+// order they have to be assumed.
+func (f *File) ResolveRole(name string) (string, []RoleProfile, error) {
+	chain := []RoleProfile{}
+	visited := map[string]bool{}
+
+	for {
+		if visited[name] {
+			return "", nil, fmt.Errorf("profile %s: source_profile loop", name)
+		}
+		visited[name] = true
+
+		// profiles missing in the config file may still have credentials
+		keys := f.Keys(ProfileSection(name))
+		if keys["role_arn"] == "" {
+			// reverse to assume the role closest to the base credentials first
+			for i, j := 0, len(chain)-1; i < j; i, j = i+1, j-1 {
+				chain[i], chain[j] = chain[j], chain[i]
+			}
+			return name, chain, nil
+		}
+
+		p := RoleProfile{
+			Name:            name,
+			RoleArn:         keys["role_arn"],
+			MfaSerial:       keys["mfa_serial"],
+			RoleSessionName: keys["role_session_name"],
+			ExternalID:      keys["external_id"],
+
+			SessionTags:           keys["session_tags"],
+			TransitiveSessionTags: keys["transitive_session_tags"],
+			SourceIdentity:        keys["source_identity"],
+		}
+		if v := keys["duration_seconds"]; v != "" {
+			seconds, err := strconv.Atoi(v)
+			if err != nil {
+				return "", nil, fmt.Errorf("profile %s: invalid duration_seconds %q", name, v)
+			}
+			p.DurationSeconds = seconds
+		}
+		chain = append(chain, p)
+
+		source := keys["source_profile"]
+		switch {
+		case source == "" && keys["credential_source"] != "":
+			return "", nil, fmt.Errorf("profile %s: credential_source is not supported, use source_profile", name)
+		case source == "":
+			return "", nil, fmt.Errorf("profile %s: role_arn requires source_profile", name)
+		case source == name:
+			return "", nil, fmt.Errorf("profile %s: source_profile referencing the profile itself is not supported", name)
+		}
+		name = source
+	}
+}
@@ -0,0 +1,91 @@
+package awsconfig
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SSOProfile holds the IAM Identity Center settings of a profile in the shared
+// config file.
+type SSOProfile struct {
+	Name      string
+	StartURL  string
+	Region    string
+	AccountID string
+	RoleName  string
+
+	// Session is the name of the sso-session section, empty for profiles
+	// with the legacy sso_start_url and sso_region settings
+	Session string
+}
+
+// CacheKey returns the key the AWS CLI stores the access token under: the
+// sso-session name, or the start URL for legacy profiles.
+func (p SSOProfile) CacheKey() string {
+	if p.Session != "" {
+		return p.Session
+	}
+	return p.StartURL
+}
+
+// SSOSessionSection returns the section name of an sso-session in the shared
+// config file.
+func SSOSessionSection(name string) string {
+	return "sso-session " + name
+}
+
+// SSO reads the IAM Identity Center settings of the named profile, following
+// its sso_session setting.
+func (f *File) SSO(name string) (SSOProfile, error) {
+	keys := f.Keys(ProfileSection(name))
+	p := SSOProfile{
+		Name:      name,
+		StartURL:  keys["sso_start_url"],
+		Region:    keys["sso_region"],
+		AccountID: keys["sso_account_id"],
+		RoleName:  keys["sso_role_name"],
+		Session:   keys["sso_session"],
+	}
+
+	if p.Session != "" {
+		session := f.Keys(SSOSessionSection(p.Session))
+		if session == nil {
+			return SSOProfile{}, fmt.Errorf("profile %s: sso-session %s not found", name, p.Session)
+		}
+		p.StartURL = session["sso_start_url"]
+		p.Region = session["sso_region"]
+	}
+
+	if p.StartURL == "" || p.Region == "" {
+		return SSOProfile{}, fmt.Errorf("profile %s: sso_start_url and sso_region are required", name)
+	}
+	return p, nil
+}
+
+// SSOSessions returns the distinct IAM Identity Center logins configured in
+// the file: every sso-session section and every start URL of profiles with
+// legacy settings.
+func (f *File) SSOSessions() []SSOProfile {
+	sessions := []SSOProfile{}
+	seen := map[string]bool{}
+	add := func(p SSOProfile) {
+		p.Name, p.AccountID, p.RoleName = "", "", ""
+		if p.StartURL != "" && p.Region != "" && !seen[p.CacheKey()] {
+			seen[p.CacheKey()] = true
+			sessions = append(sessions, p)
+		}
+	}
+
+	for _, section := range f.Sections() {
+		if name, ok := strings.CutPrefix(section, "sso-session "); ok {
+			keys := f.Keys(section)
+			add(SSOProfile{StartURL: keys["sso_start_url"], Region: keys["sso_region"], Session: strings.TrimSpace(name)})
+		}
+	}
+	for _, name := range f.Profiles() {
+		if p, err := f.SSO(name); err == nil && p.Session == "" {
+			add(p)
+		}
+	}
+	return sessions
+}
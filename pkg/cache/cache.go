@@ -0,0 +1,187 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Key identifies a cached session. Credentials are only reused when every
+// field matches.
+type Key struct {
+	SourceIdentity string
+	// Roles are the roles assumed in order, the session is one of the last
+	Roles     []Role `json:",omitempty"`
+	Account   string `json:",omitempty"`
+	MfaSerial string `json:",omitempty"`
+	Duration  int
+}
+
+// Role is a role of the chain a session was obtained with.
+type Role struct {
+	Arn      string
+	Duration int `json:",omitempty"`
+	// Settings are the session tags, source identity, external ID and
+	// session policy of the role.
+	Settings string `json:",omitempty"`
+}
+
+func (k Key) hash() string {
+	b, _ := json.Marshal(k)
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// Entry is a cached session.
+type Entry struct {
+	Key             Key
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	Expiration      time.Time
+}
+
+// Cache stores sessions as 0600 JSON files in Dir.
+type Cache struct {
+	Dir string
+}
+
+// New returns a cache in the user cache directory, $XDG_CACHE_HOME/aws-login
+// on Linux.
+func New() (*Cache, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return nil, err
+	}
+	return &Cache{Dir: filepath.Join(dir, "aws-login")}, nil
+}
+
+// Get returns the session stored under key, or nil if there is none or it
+// expires within refreshWindow.
+func (c *Cache) Get(key Key, refreshWindow time.Duration) (*Entry, error) {
+	e, err := c.read(filepath.Join(c.Dir, key.hash()+".json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if e.Key.hash() != key.hash() || time.Until(e.Expiration) <= refreshWindow {
+		return nil, nil
+	}
+	return e, nil
+}
+
+// Put stores a session, replacing any previous one with the same key.
+func (c *Cache) Put(e Entry) error {
+	if err := os.MkdirAll(c.Dir, 0700); err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(c.Dir, ".entry.*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if _, err := tmp.Write(b); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), filepath.Join(c.Dir, e.Key.hash()+".json"))
+}
+
+// List returns all cached sessions ordered by expiration, including expired
+// ones.
+func (c *Cache) List() ([]Entry, error) {
+	// other files such as the recently picked roles share the directory,
+	// sessions are named by the hash of their key
+	paths, err := filepath.Glob(filepath.Join(c.Dir, strings.Repeat("[0-9a-f]", sha256.Size*2)+".json"))
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []Entry{}
+	for _, path := range paths {
+		e, err := c.read(path)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, *e)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Expiration.Before(entries[j].Expiration)
+	})
+	return entries, nil
+}
+
+// Clear removes cached sessions and returns how many were removed. When role
+// is not empty only sessions with that role, given as a name or an ARN,
+// anywhere in their chain are removed.
+func (c *Cache) Clear(role string) (int, error) {
+	entries, err := c.List()
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, e := range entries {
+		if role != "" && !e.matchesRole(role) {
+			continue
+		}
+
+		if err := os.Remove(filepath.Join(c.Dir, e.Key.hash()+".json")); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+func (e Entry) matchesRole(role string) bool {
+	for _, r := range e.Key.Roles {
+		if r.Arn == role || strings.HasSuffix(r.Arn, ":role/"+role) {
+			return true
+		}
+	}
+	return false
+}
+
+// RoleArns returns the ARNs of the roles of k, comma separated.
+func (k Key) RoleArns() string {
+	arns := make([]string, len(k.Roles))
+	for i, r := range k.Roles {
+		arns[i] = r.Arn
+	}
+	return strings.Join(arns, ",")
+}
+
+func (c *Cache) read(path string) (*Entry, error) {
+	b, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+
+	e := &Entry{}
+	if err := json.Unmarshal(b, e); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
@@ -0,0 +1,112 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestGetPut(t *testing.T) {
+	c := &Cache{Dir: filepath.Join(t.TempDir(), "aws-login")}
+	key := Key{
+		SourceIdentity: "arn:aws:iam::111111111111:user/alice",
+		Roles:          []Role{{Arn: "arn:aws:iam::222222222222:role/admin", Duration: 3600}},
+		Account:        "222222222222",
+		Duration:       3600,
+	}
+
+	e, err := c.Get(key, time.Minute)
+	if err != nil || e != nil {
+		t.Fatalf("expected miss on empty cache, got %v, %v", e, err)
+	}
+
+	if err := c.Put(Entry{Key: key, AccessKeyID: "ASIA", Expiration: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		key           Key
+		refreshWindow time.Duration
+		wantHit       bool
+	}{
+		{key: key, refreshWindow: time.Minute, wantHit: true},
+		{key: key, refreshWindow: 2 * time.Hour, wantHit: false},
+		{key: Key{SourceIdentity: key.SourceIdentity, Roles: key.Roles, Account: key.Account, Duration: 900}, refreshWindow: time.Minute, wantHit: false},
+		// the same role reached through another one
+		{key: Key{SourceIdentity: key.SourceIdentity, Roles: []Role{{Arn: "arn:aws:iam::111111111111:role/jump"}, key.Roles[0]}, Account: key.Account, Duration: 3600}, refreshWindow: time.Minute, wantHit: false},
+		{key: Key{SourceIdentity: key.SourceIdentity, Roles: []Role{{Arn: key.Roles[0].Arn, Duration: 3600, Settings: "team=x"}}, Account: key.Account, Duration: 3600}, refreshWindow: time.Minute, wantHit: false},
+	}
+
+	for _, test := range tests {
+		e, err := c.Get(test.key, test.refreshWindow)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (e != nil) != test.wantHit {
+			t.Errorf("key=%v, refreshWindow=%v: got hit %v, expected %v", test.key, test.refreshWindow, e != nil, test.wantHit)
+		}
+	}
+
+	if runtime.GOOS != "windows" {
+		paths, _ := filepath.Glob(filepath.Join(c.Dir, "*.json"))
+		for _, path := range paths {
+			info, err := os.Stat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Mode().Perm() != 0600 {
+				t.Errorf("%s has permissions %v", path, info.Mode().Perm())
+			}
+		}
+	}
+}
+
+func TestClear(t *testing.T) {
+	c := &Cache{Dir: t.TempDir()}
+	keys := []Key{
+		{SourceIdentity: "alice", Duration: 3600},
+		{SourceIdentity: "alice", Roles: []Role{{Arn: "arn:aws:iam::222222222222:role/admin"}}, Duration: 3600},
+		{SourceIdentity: "alice", Roles: []Role{{Arn: "arn:aws:iam::333333333333:role/admin"}}, Duration: 3600},
+		{SourceIdentity: "alice", Roles: []Role{{Arn: "arn:aws:iam::333333333333:role/readonly"}}, Duration: 3600},
+		{SourceIdentity: "alice", Roles: []Role{{Arn: "arn:aws:iam::333333333333:role/readonly"}, {Arn: "arn:aws:iam::444444444444:role/deploy"}}, Duration: 3600},
+	}
+	for _, key := range keys {
+		if err := c.Put(Entry{Key: key, Expiration: time.Now().Add(time.Hour)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// files of other features in the same directory are not sessions
+	if err := os.WriteFile(filepath.Join(c.Dir, "recent.json"), []byte("[]"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		role        string
+		wantRemoved int
+		wantLeft    int
+	}{
+		{role: "arn:aws:iam::333333333333:role/readonly", wantRemoved: 2, wantLeft: 3},
+		{role: "admin", wantRemoved: 2, wantLeft: 1},
+		{role: "", wantRemoved: 1, wantLeft: 0},
+	}
+
+	for _, test := range tests {
+		removed, err := c.Clear(test.role)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if removed != test.wantRemoved {
+			t.Errorf("role=%q: removed %d, expected %d", test.role, removed, test.wantRemoved)
+		}
+
+		entries, err := c.List()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != test.wantLeft {
+			t.Errorf("role=%q: %d entries left, expected %d", test.role, len(entries), test.wantLeft)
+		}
+	}
+}
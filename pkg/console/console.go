@@ -0,0 +1,113 @@
+package console
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/michalschott/aws-login/pkg/format"
+)
+
+// Partition holds the sign-in and console hosts of an AWS partition.
+type Partition struct {
+	Name       string
+	Federation string
+	Console    string
+}
+
+var partitions = map[string]Partition{
+	"aws":        {Name: "aws", Federation: "https://signin.aws.amazon.com/federation", Console: "https://console.aws.amazon.com"},
+	"aws-us-gov": {Name: "aws-us-gov", Federation: "https://signin.amazonaws-us-gov.com/federation", Console: "https://console.amazonaws-us-gov.com"},
+	"aws-cn":     {Name: "aws-cn", Federation: "https://signin.amazonaws.cn/federation", Console: "https://console.amazonaws.cn"},
+}
+
+// Lookup returns the hosts of the named partition, falling back to the
+// commercial one for unknown names.
+func Lookup(name string) Partition {
+	if p, ok := partitions[name]; ok {
+		return p
+	}
+	return partitions["aws"]
+}
+
+// Destination turns a console path into a URL. Full URLs are kept as they
+// are.
+func (p Partition) Destination(path string) string {
+	if strings.HasPrefix(path, "https://") || strings.HasPrefix(path, "http://") {
+		return path
+	}
+	if path == "" {
+		path = "/console/home"
+	}
+	return p.Console + "/" + strings.TrimPrefix(path, "/")
+}
+
+// HTTPClient sends requests to the federation endpoint. *http.Client and the
+// client of aws.Config implement it.
+type HTTPClient interface {
+	Do(*http.Request) (*http.Response, error)
+}
+
+// SigninToken exchanges temporary credentials for a sign-in token at the
+// federation endpoint.
+func SigninToken(ctx context.Context, client HTTPClient, endpoint string, c format.Credentials) (string, error) {
+	session, err := json.Marshal(map[string]string{
+		"sessionId":    c.AccessKeyID,
+		"sessionKey":   c.SecretAccessKey,
+		"sessionToken": c.SessionToken,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	query := url.Values{}
+	query.Set("Action", "getSigninToken")
+	query.Set("Session", string(session))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+"?"+query.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("federation endpoint returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var token struct {
+		SigninToken string
+	}
+	if err := json.Unmarshal(body, &token); err != nil {
+		return "", fmt.Errorf("invalid federation response: %w", err)
+	}
+	if token.SigninToken == "" {
+		return "", fmt.Errorf("federation response has no sign-in token")
+	}
+	return token.SigninToken, nil
+}
+
+// LoginURL builds the URL signing in to the console with token and opening
+// destination. Issuer is where the console sends the user once the session
+// expires and may be empty.
+func LoginURL(endpoint, token, destination, issuer string) string {
+	query := url.Values{}
+	query.Set("Action", "login")
+	query.Set("Destination", destination)
+	query.Set("SigninToken", token)
+	if issuer != "" {
+		query.Set("Issuer", issuer)
+	}
+	return endpoint + "?" + query.Encode()
+}
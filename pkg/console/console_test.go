@@ -0,0 +1,105 @@
+package console
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/michalschott/aws-login/pkg/format"
+)
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		name        string
+		federation  string
+		destination string
+	}{
+		{name: "aws", federation: "https://signin.aws.amazon.com/federation", destination: "https://console.aws.amazon.com/console/home"},
+		{name: "aws-iso", federation: "https://signin.aws.amazon.com/federation", destination: "https://console.aws.amazon.com/console/home"},
+		{name: "aws-us-gov", federation: "https://signin.amazonaws-us-gov.com/federation", destination: "https://console.amazonaws-us-gov.com/console/home"},
+		{name: "aws-cn", federation: "https://signin.amazonaws.cn/federation", destination: "https://console.amazonaws.cn/console/home"},
+	}
+
+	for _, tt := range tests {
+		p := Lookup(tt.name)
+		if p.Federation != tt.federation || p.Destination("") != tt.destination {
+			t.Errorf("partition %q is %+v", tt.name, p)
+		}
+	}
+}
+
+func TestDestination(t *testing.T) {
+	p := Lookup("aws")
+	tests := map[string]string{
+		"/ec2/home?region=eu-west-1": "https://console.aws.amazon.com/ec2/home?region=eu-west-1",
+		"s3/home":                    "https://console.aws.amazon.com/s3/home",
+		"https://example.com/x":      "https://example.com/x",
+	}
+	for path, want := range tests {
+		if got := p.Destination(path); got != want {
+			t.Errorf("destination of %q is %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestSigninToken(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var session map[string]string
+		if r.URL.Query().Get("Action") != "getSigninToken" || json.Unmarshal([]byte(r.URL.Query().Get("Session")), &session) != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		if session["sessionToken"] == "" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		_, _ = w.Write([]byte(`{"SigninToken":"token-for-` + session["sessionId"] + `"}`))
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		credentials format.Credentials
+		want        string
+		wantErr     bool
+	}{
+		{credentials: format.Credentials{AccessKeyID: "ASIA", SecretAccessKey: "secret", SessionToken: "token"}, want: "token-for-ASIA"},
+		{credentials: format.Credentials{AccessKeyID: "AKIA", SecretAccessKey: "secret"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := SigninToken(context.Background(), srv.Client(), srv.URL, tt.credentials)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("err is wrong, value=%v, wantErr=%v, err=%v", tt.credentials.AccessKeyID, tt.wantErr, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("token is %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestLoginURL(t *testing.T) {
+	tests := []struct {
+		issuer string
+	}{
+		{issuer: ""},
+		{issuer: "https://example.com/login"},
+	}
+
+	for _, tt := range tests {
+		u, err := url.Parse(LoginURL("https://signin.aws.amazon.com/federation", "tok en", "https://console.aws.amazon.com/", tt.issuer))
+		if err != nil {
+			t.Fatal(err)
+		}
+		q := u.Query()
+		if q.Get("Action") != "login" || q.Get("SigninToken") != "tok en" || q.Get("Destination") != "https://console.aws.amazon.com/" {
+			t.Errorf("wrong query %v", q)
+		}
+		if _, ok := q["Issuer"]; ok != (tt.issuer != "") || q.Get("Issuer") != tt.issuer {
+			t.Errorf("wrong issuer in %v", q)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package ecs
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/michalschott/aws-login/pkg/format"
+)
+
+// Server implements the container credentials endpoint of ECS, read by the
+// AWS SDKs from AWS_CONTAINER_CREDENTIALS_FULL_URI. Requests have to carry
+// Token in the Authorization header, as given in
+// AWS_CONTAINER_AUTHORIZATION_TOKEN.
+type Server struct {
+	Token string
+	// Credentials returns the credentials to serve, it is called for every
+	// request so it can refresh them
+	Credentials func(ctx context.Context) (format.Credentials, error)
+}
+
+type credentialsOutput struct {
+	AccessKeyId     string
+	SecretAccessKey string
+	Token           string
+	Expiration      string
+}
+
+// errorOutput is the error document understood by the SDKs.
+type errorOutput struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, errorOutput{Code: "MethodNotAllowed", Message: "only GET is supported"})
+		return
+	}
+
+	// the SDKs send the bare token, other clients may prefix it
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if s.Token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(s.Token)) != 1 {
+		writeJSON(w, http.StatusUnauthorized, errorOutput{Code: "Unauthorized", Message: "missing or wrong authorization token"})
+		return
+	}
+
+	c, err := s.Credentials(r.Context())
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, errorOutput{Code: "CredentialsUnavailable", Message: err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, credentialsOutput{
+		AccessKeyId:     c.AccessKeyID,
+		SecretAccessKey: c.SecretAccessKey,
+		Token:           c.SessionToken,
+		Expiration:      c.Expiration.UTC().Format(time.RFC3339),
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
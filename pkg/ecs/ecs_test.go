@@ -0,0 +1,59 @@
+package ecs
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials/endpointcreds"
+
+	"github.com/michalschott/aws-login/pkg/format"
+)
+
+func TestServer(t *testing.T) {
+	expiration := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	failing := false
+	s := &Server{
+		Token: "secret-token",
+		Credentials: func(ctx context.Context) (format.Credentials, error) {
+			if failing {
+				return format.Credentials{}, errors.New("session expired")
+			}
+			return format.Credentials{AccessKeyID: "ASIA", SecretAccessKey: "secret", SessionToken: "token", Expiration: expiration}, nil
+		},
+	}
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	tests := []struct {
+		token   string
+		failing bool
+		wantErr bool
+	}{
+		{token: "secret-token"},
+		{token: "Bearer secret-token"},
+		{token: "wrong", wantErr: true},
+		{token: "", wantErr: true},
+		{token: "secret-token", failing: true, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		failing = tt.failing
+		// the SDK reads the credentials as it would in a container
+		provider := endpointcreds.New(srv.URL+"/", func(o *endpointcreds.Options) {
+			o.AuthorizationToken = tt.token
+			o.Retryer = aws.NopRetryer{}
+		})
+		c, err := provider.Retrieve(context.Background())
+		if (err != nil) != tt.wantErr {
+			t.Errorf("err is wrong, value=%v, wantErr=%v, err=%v", tt.token, tt.wantErr, err)
+			continue
+		}
+		if err == nil && (c.AccessKeyID != "ASIA" || c.SessionToken != "token" || !c.Expires.Equal(expiration)) {
+			t.Errorf("got %+v", c)
+		}
+	}
+}
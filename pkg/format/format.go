@@ -0,0 +1,74 @@
+package format
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Default is the name of the formatter used when none is requested.
+const Default = "sh"
+
+// Credentials are the temporary credentials written by a Formatter.
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	Expiration      time.Time
+	// Region is exported as AWS_REGION and AWS_DEFAULT_REGION when set
+	Region string
+}
+
+// Variable is a single environment variable to be exported.
+type Variable struct {
+	Name  string
+	Value string
+}
+
+// Env returns credentials as an ordered list of environment variables.
+func (c Credentials) Env() []Variable {
+	vars := []Variable{
+		{"AWS_ACCESS_KEY_ID", c.AccessKeyID},
+		{"AWS_SECRET_ACCESS_KEY", c.SecretAccessKey},
+		{"AWS_SESSION_TOKEN", c.SessionToken},
+	}
+	if c.Region != "" {
+		vars = append(vars, Variable{"AWS_REGION", c.Region}, Variable{"AWS_DEFAULT_REGION", c.Region})
+	}
+	return vars
+}
+
+// Formatter writes credentials in a syntax understood by a shell or tool.
+type Formatter interface {
+	Format(w io.Writer, c Credentials) error
+}
+
+var formatters = map[string]Formatter{}
+
+// Register makes a formatter available under the given names.
+func Register(f Formatter, names ...string) {
+	for _, name := range names {
+		formatters[name] = f
+	}
+}
+
+// Get returns the formatter registered under name.
+func Get(name string) (Formatter, error) {
+	f, ok := formatters[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("unknown format %q, valid formats are: %s", name, strings.Join(Names(), ", "))
+	}
+	return f, nil
+}
+
+// Names returns all registered formatter names, sorted.
+func Names() []string {
+	names := make([]string, 0, len(formatters))
+	for name := range formatters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
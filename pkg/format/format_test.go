@@ -0,0 +1,152 @@
+package format
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestFormat(t *testing.T) {
+	c := Credentials{
+		AccessKeyID:     "AKIA",
+		SecretAccessKey: "se'cr$et",
+		SessionToken:    "to/ken+=",
+	}
+
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{
+			format: "sh",
+			want:   "export AWS_ACCESS_KEY_ID=AKIA\nexport AWS_SECRET_ACCESS_KEY='se'\\''cr$et'\nexport AWS_SESSION_TOKEN=to/ken+=\n",
+		},
+		{
+			format: "fish",
+			want:   "set -gx AWS_ACCESS_KEY_ID 'AKIA';\nset -gx AWS_SECRET_ACCESS_KEY 'se\\'cr$et';\nset -gx AWS_SESSION_TOKEN 'to/ken+=';\n",
+		},
+		{
+			format: "powershell",
+			want:   "$Env:AWS_ACCESS_KEY_ID = 'AKIA'\n$Env:AWS_SECRET_ACCESS_KEY = 'se''cr$et'\n$Env:AWS_SESSION_TOKEN = 'to/ken+='\n",
+		},
+		{
+			format: "cmd",
+			want:   "set \"AWS_ACCESS_KEY_ID=AKIA\"\nset \"AWS_SECRET_ACCESS_KEY=se'cr$et\"\nset \"AWS_SESSION_TOKEN=to/ken+=\"\n",
+		},
+		{
+			format: "nushell",
+			want:   "$env.AWS_ACCESS_KEY_ID = \"AKIA\"\n$env.AWS_SECRET_ACCESS_KEY = \"se'cr$et\"\n$env.AWS_SESSION_TOKEN = \"to/ken+=\"\n",
+		},
+	}
+
+	for _, test := range tests {
+		f, err := Get(test.format)
+		if err != nil {
+			t.Fatalf("format %s: %v", test.format, err)
+		}
+
+		var output bytes.Buffer
+		if err := f.Format(&output, c); err != nil {
+			t.Errorf("format %s: unexpected error %v", test.format, err)
+			continue
+		}
+		if output.String() != test.want {
+			t.Errorf("format %s: got %q but expected %q", test.format, output.String(), test.want)
+		}
+	}
+}
+
+func TestFormatCmdRejectsUnquotable(t *testing.T) {
+	f, err := Get("cmd")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var output bytes.Buffer
+	err = f.Format(&output, Credentials{AccessKeyID: `100%"`})
+	if err == nil {
+		t.Errorf("expected error, got output %q", output.String())
+	}
+}
+
+func TestFormatDotenv(t *testing.T) {
+	f, err := Get("dotenv")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// $ stays literal in single quotes
+	var output bytes.Buffer
+	if err := f.Format(&output, Credentials{AccessKeyID: "AKIA", SecretAccessKey: `se$cr"et`, SessionToken: "to/ken+="}); err != nil {
+		t.Fatal(err)
+	}
+	want := "AWS_ACCESS_KEY_ID=AKIA\nAWS_SECRET_ACCESS_KEY='se$cr\"et'\nAWS_SESSION_TOKEN=to/ken+=\n"
+	if output.String() != want {
+		t.Errorf("got %s but expected %s", output.String(), want)
+	}
+
+	for _, value := range []string{"se'cret", "se\ncret"} {
+		output.Reset()
+		if err := f.Format(&output, Credentials{SecretAccessKey: value}); err == nil {
+			t.Errorf("expected error for %q, got output %q", value, output.String())
+		}
+	}
+}
+
+func TestGetUnknown(t *testing.T) {
+	if _, err := Get("tcsh"); err == nil {
+		t.Error("expected error for unknown format")
+	}
+}
+
+func TestFormatCredentialProcess(t *testing.T) {
+	f, err := Get("credential-process")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		credentials Credentials
+		want        string
+	}{
+		{
+			credentials: Credentials{
+				AccessKeyID:     "AKIA",
+				SecretAccessKey: "secret",
+				SessionToken:    "token",
+				Expiration:      time.Date(2020, 1, 2, 3, 4, 5, 0, time.FixedZone("CET", 3600)),
+				Region:          "eu-west-1",
+			},
+			want: `{"Version":1,"AccessKeyId":"AKIA","SecretAccessKey":"secret","SessionToken":"token","Expiration":"2020-01-02T02:04:05Z"}` + "\n",
+		},
+		{
+			credentials: Credentials{AccessKeyID: "AKIA", SecretAccessKey: "secret"},
+			want:        `{"Version":1,"AccessKeyId":"AKIA","SecretAccessKey":"secret"}` + "\n",
+		},
+	}
+
+	for _, test := range tests {
+		var output bytes.Buffer
+		if err := f.Format(&output, test.credentials); err != nil {
+			t.Errorf("unexpected error %v", err)
+			continue
+		}
+		if output.String() != test.want {
+			t.Errorf("got %s but expected %s", output.String(), test.want)
+		}
+	}
+}
+
+func TestEnvRegion(t *testing.T) {
+	c := Credentials{AccessKeyID: "AKIA", SecretAccessKey: "secret", SessionToken: "token"}
+	if got := len(c.Env()); got != 3 {
+		t.Errorf("got %d variables without a region", got)
+	}
+
+	c.Region = "us-gov-west-1"
+	env := c.Env()
+	want := []Variable{{"AWS_REGION", "us-gov-west-1"}, {"AWS_DEFAULT_REGION", "us-gov-west-1"}}
+	if len(env) != 5 || env[3] != want[0] || env[4] != want[1] {
+		t.Errorf("got %v", env)
+	}
+}
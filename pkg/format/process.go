@@ -0,0 +1,37 @@
+package format
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// CredentialProcess writes the versioned JSON document expected from a
+// credential_process entry in the shared AWS config file.
+type CredentialProcess struct{}
+
+type credentialProcessOutput struct {
+	Version         int
+	AccessKeyId     string
+	SecretAccessKey string
+	SessionToken    string `json:",omitempty"`
+	Expiration      string `json:",omitempty"`
+}
+
+func (CredentialProcess) Format(w io.Writer, c Credentials) error {
+	output := credentialProcessOutput{
+		Version:         1,
+		AccessKeyId:     c.AccessKeyID,
+		SecretAccessKey: c.SecretAccessKey,
+		SessionToken:    c.SessionToken,
+	}
+	if !c.Expiration.IsZero() {
+		output.Expiration = c.Expiration.UTC().Format(time.RFC3339)
+	}
+
+	return json.NewEncoder(w).Encode(output)
+}
+
+func init() {
+	Register(CredentialProcess{}, "credential-process", "json")
+}
@@ -0,0 +1,102 @@
+package format
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Shell formats credentials one variable per line. Adding support for a new
+// shell only requires a function rendering a single assignment.
+type Shell func(name, value string) (string, error)
+
+func (s Shell) Format(w io.Writer, c Credentials) error {
+	for _, v := range c.Env() {
+		line, err := s(v.Name, v.Value)
+		if err != nil {
+			return err
+		}
+
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func init() {
+	Register(Shell(posix), "sh", "bash", "zsh")
+	Register(Shell(fish), "fish")
+	Register(Shell(powershell), "powershell", "pwsh")
+	Register(Shell(cmd), "cmd")
+	Register(Shell(nushell), "nushell", "nu")
+	Register(Shell(dotenv), "dotenv")
+}
+
+func posix(name, value string) (string, error) {
+	return fmt.Sprintf("export %s=%s", name, posixQuote(value)), nil
+}
+
+func fish(name, value string) (string, error) {
+	r := strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+	return fmt.Sprintf("set -gx %s '%s';", name, r.Replace(value)), nil
+}
+
+func powershell(name, value string) (string, error) {
+	return fmt.Sprintf("$Env:%s = '%s'", name, strings.ReplaceAll(value, `'`, `''`)), nil
+}
+
+// cmd.exe has no way of escaping quotes or percent signs inside a quoted set,
+// so such values are rejected rather than printed in a form that would break.
+func cmd(name, value string) (string, error) {
+	if strings.ContainsAny(value, "\"%\r\n") {
+		return "", fmt.Errorf("value of %s can not be represented in cmd syntax", name)
+	}
+	return fmt.Sprintf(`set "%s=%s"`, name, value), nil
+}
+
+func nushell(name, value string) (string, error) {
+	return fmt.Sprintf("$env.%s = %s", name, doubleQuote(value)), nil
+}
+
+// dotenv loaders such as docker compose and python-dotenv interpolate $ inside
+// double quotes but take single quoted values literally. They disagree on
+// escaping a single quote, so values holding one are rejected.
+func dotenv(name, value string) (string, error) {
+	if isSafe(value) {
+		return fmt.Sprintf("%s=%s", name, value), nil
+	}
+	if strings.ContainsAny(value, "'\r\n") {
+		return "", fmt.Errorf("value of %s can not be represented in dotenv syntax", name)
+	}
+	return fmt.Sprintf("%s='%s'", name, value), nil
+}
+
+// posixQuote leaves values made of safe characters untouched and wraps
+// everything else in single quotes.
+func posixQuote(value string) string {
+	if isSafe(value) {
+		return value
+	}
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+func doubleQuote(value string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
+	return `"` + r.Replace(value) + `"`
+}
+
+func isSafe(value string) bool {
+	if value == "" {
+		return false
+	}
+	for _, r := range value {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case strings.ContainsRune("_@%+=:,./-", r):
+		default:
+			return false
+		}
+	}
+	return true
+}
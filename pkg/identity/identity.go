@@ -0,0 +1,81 @@
+package identity
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+)
+
+// Principal types of caller ARNs.
+const (
+	TypeUser          = "user"
+	TypeAssumedRole   = "assumed-role"
+	TypeFederatedUser = "federated-user"
+	TypeRoot          = "root"
+)
+
+// PartitionOf returns the partition region belongs to.
+func PartitionOf(region string) string {
+	switch {
+	case strings.HasPrefix(region, "us-gov-"):
+		return "aws-us-gov"
+	case strings.HasPrefix(region, "cn-"):
+		return "aws-cn"
+	default:
+		return "aws"
+	}
+}
+
+// Principal is a caller ARN split into its parts.
+type Principal struct {
+	Partition   string `json:"partition"`
+	Account     string `json:"account"`
+	Type        string `json:"type"`
+	Name        string `json:"name,omitempty"`
+	Path        string `json:"path,omitempty"`
+	RoleName    string `json:"roleName,omitempty"`
+	SessionName string `json:"sessionName,omitempty"`
+}
+
+// Parse splits a caller ARN as returned by GetCallerIdentity.
+func Parse(callerArn string) (Principal, error) {
+	a, err := arn.Parse(callerArn)
+	if err != nil {
+		return Principal{}, err
+	}
+	if a.Service != "iam" && a.Service != "sts" {
+		return Principal{}, fmt.Errorf("%s is not an IAM or STS principal", callerArn)
+	}
+
+	p := Principal{Partition: a.Partition, Account: a.AccountID}
+	if a.Resource == TypeRoot {
+		p.Type = TypeRoot
+		return p, nil
+	}
+
+	kind, rest, ok := strings.Cut(a.Resource, "/")
+	if !ok || rest == "" {
+		return Principal{}, fmt.Errorf("unknown principal %s", callerArn)
+	}
+	p.Type = kind
+
+	switch kind {
+	case TypeAssumedRole:
+		role, session, ok := strings.Cut(rest, "/")
+		if !ok {
+			return Principal{}, fmt.Errorf("assumed role %s has no session name", callerArn)
+		}
+		p.Name, p.RoleName, p.SessionName = role, role, session
+	case TypeUser, TypeFederatedUser:
+		// user names may be prefixed by a path
+		if i := strings.LastIndex(rest, "/"); i >= 0 {
+			p.Path, p.Name = "/"+rest[:i+1], rest[i+1:]
+		} else {
+			p.Name = rest
+		}
+	default:
+		return Principal{}, fmt.Errorf("unknown principal type %s in %s", kind, callerArn)
+	}
+	return p, nil
+}
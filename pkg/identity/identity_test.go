@@ -0,0 +1,62 @@
+package identity
+
+import "testing"
+
+func TestPartitionOf(t *testing.T) {
+	tests := map[string]string{
+		"eu-west-1":      "aws",
+		"":               "aws",
+		"us-gov-west-1":  "aws-us-gov",
+		"cn-north-1":     "aws-cn",
+		"cn-northwest-1": "aws-cn",
+	}
+	for region, want := range tests {
+		if got := PartitionOf(region); got != want {
+			t.Errorf("partition of %q is %s, want %s", region, got, want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		arn     string
+		want    Principal
+		wantErr bool
+	}{
+		{
+			arn:  "arn:aws:iam::123456789012:user/alice",
+			want: Principal{Partition: "aws", Account: "123456789012", Type: TypeUser, Name: "alice"},
+		},
+		{
+			arn:  "arn:aws:iam::123456789012:user/division/team/alice",
+			want: Principal{Partition: "aws", Account: "123456789012", Type: TypeUser, Name: "alice", Path: "/division/team/"},
+		},
+		{
+			arn:  "arn:aws-us-gov:sts::123456789012:assumed-role/Admin/alice",
+			want: Principal{Partition: "aws-us-gov", Account: "123456789012", Type: TypeAssumedRole, Name: "Admin", RoleName: "Admin", SessionName: "alice"},
+		},
+		{
+			arn:  "arn:aws-cn:sts::123456789012:federated-user/bob",
+			want: Principal{Partition: "aws-cn", Account: "123456789012", Type: TypeFederatedUser, Name: "bob"},
+		},
+		{
+			arn:  "arn:aws:iam::123456789012:root",
+			want: Principal{Partition: "aws", Account: "123456789012", Type: TypeRoot},
+		},
+		{arn: "arn:aws:sts::123456789012:assumed-role/Admin", wantErr: true},
+		{arn: "arn:aws:s3:::bucket/key", wantErr: true},
+		{arn: "arn:aws:iam::123456789012:group/devs", wantErr: true},
+		{arn: "not an arn", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := Parse(tt.arn)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("err is wrong, value=%v, wantErr=%v, err=%v", tt.arn, tt.wantErr, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Parse(%s) = %+v, want %+v", tt.arn, got, tt.want)
+		}
+	}
+}
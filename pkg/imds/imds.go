@@ -0,0 +1,146 @@
+package imds
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/michalschott/aws-login/pkg/format"
+	"github.com/michalschott/aws-login/pkg/random"
+)
+
+const (
+	tokenHeader    = "X-aws-ec2-metadata-token"
+	tokenTTLHeader = "X-aws-ec2-metadata-token-ttl-seconds"
+
+	credentialsPath = "/latest/meta-data/iam/security-credentials/"
+
+	// maxTokenTTL is the longest session token lifetime EC2 accepts.
+	maxTokenTTL = 21600
+)
+
+// Server implements the IMDSv2 endpoints the AWS SDKs read role credentials
+// and the region from. Every request has to carry a session token obtained
+// with PUT /latest/api/token, as on instances requiring IMDSv2.
+type Server struct {
+	// Role is the name the credentials are listed under
+	Role   string
+	Region string
+	// Credentials returns the credentials to serve, it is called for every
+	// request so it can refresh them
+	Credentials func(ctx context.Context) (format.Credentials, error)
+
+	mu     sync.Mutex
+	tokens map[string]time.Time
+}
+
+// credentialsOutput is the document of security-credentials/ROLE.
+type credentialsOutput struct {
+	Code            string
+	LastUpdated     string
+	Type            string
+	AccessKeyId     string
+	SecretAccessKey string
+	Token           string
+	Expiration      string
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// EC2 refuses token requests passed through a proxy
+	if r.URL.Path == "/latest/api/token" {
+		if r.Method != http.MethodPut || r.Header.Get("X-Forwarded-For") != "" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		s.token(w, r)
+		return
+	}
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.valid(r.Header.Get(tokenHeader)) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	switch r.URL.Path {
+	case credentialsPath, strings.TrimSuffix(credentialsPath, "/"):
+		_, _ = w.Write([]byte(s.Role))
+	case credentialsPath + s.Role:
+		s.credentials(w, r)
+	case "/latest/meta-data/placement/region":
+		_, _ = w.Write([]byte(s.Region))
+	case "/latest/dynamic/instance-identity/document":
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]string{"region": s.Region})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) token(w http.ResponseWriter, r *http.Request) {
+	ttl, err := strconv.Atoi(r.Header.Get(tokenTTLHeader))
+	if err != nil || ttl < 1 || ttl > maxTokenTTL {
+		http.Error(w, "invalid "+tokenTTLHeader, http.StatusBadRequest)
+		return
+	}
+
+	randomStringConfig := random.RandomStringConfig{
+		Length:  56,
+		Charset: "abcdefghijklmnopqrstuvwxyz" + "ABCDEFGHIJKLMNOPQRSTUVWXYZ" + "0123456789",
+	}
+	token, err := randomStringConfig.New()
+	if err != nil {
+		http.Error(w, "can not generate token", http.StatusInternalServerError)
+		return
+	}
+
+	s.mu.Lock()
+	if s.tokens == nil {
+		s.tokens = map[string]time.Time{}
+	}
+	now := time.Now()
+	for t, expires := range s.tokens {
+		if now.After(expires) {
+			delete(s.tokens, t)
+		}
+	}
+	s.tokens[token] = now.Add(time.Duration(ttl) * time.Second)
+	s.mu.Unlock()
+
+	w.Header().Set(tokenTTLHeader, strconv.Itoa(ttl))
+	_, _ = w.Write([]byte(token))
+}
+
+func (s *Server) valid(token string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expires, ok := s.tokens[token]
+	return ok && time.Now().Before(expires)
+}
+
+func (s *Server) credentials(w http.ResponseWriter, r *http.Request) {
+	c, err := s.Credentials(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(credentialsOutput{
+		Code:            "Success",
+		LastUpdated:     time.Now().UTC().Format(time.RFC3339),
+		Type:            "AWS-HMAC",
+		AccessKeyId:     c.AccessKeyID,
+		SecretAccessKey: c.SecretAccessKey,
+		Token:           c.SessionToken,
+		Expiration:      c.Expiration.UTC().Format(time.RFC3339),
+	})
+}
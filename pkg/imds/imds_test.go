@@ -0,0 +1,86 @@
+package imds
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials/ec2rolecreds"
+	sdkimds "github.com/aws/aws-sdk-go-v2/feature/ec2/imds"
+
+	"github.com/michalschott/aws-login/pkg/format"
+)
+
+func TestServer(t *testing.T) {
+	expiration := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	calls := 0
+	s := &Server{
+		Role:   "aws-login",
+		Region: "eu-west-1",
+		Credentials: func(ctx context.Context) (format.Credentials, error) {
+			calls++
+			return format.Credentials{AccessKeyID: "ASIA", SecretAccessKey: "secret", SessionToken: "token", Expiration: expiration}, nil
+		},
+	}
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	// the SDK reads the credentials as it would on an instance
+	client := sdkimds.New(sdkimds.Options{Endpoint: srv.URL, EnableFallback: aws.FalseTernary})
+	provider := ec2rolecreds.New(func(o *ec2rolecreds.Options) { o.Client = client })
+	c, err := provider.Retrieve(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.AccessKeyID != "ASIA" || c.SecretAccessKey != "secret" || c.SessionToken != "token" || !c.Expires.Equal(expiration) || calls != 1 {
+		t.Errorf("got %+v after %d calls", c, calls)
+	}
+
+	region, err := client.GetRegion(context.Background(), nil)
+	if err != nil || region.Region != "eu-west-1" {
+		t.Errorf("got region %+v, %v", region, err)
+	}
+}
+
+func TestServerRequiresToken(t *testing.T) {
+	s := &Server{Role: "aws-login"}
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	tests := []struct {
+		method   string
+		path     string
+		header   map[string]string
+		expected int
+	}{
+		{method: http.MethodGet, path: credentialsPath, expected: http.StatusUnauthorized},
+		{method: http.MethodGet, path: credentialsPath, header: map[string]string{tokenHeader: "guessed"}, expected: http.StatusUnauthorized},
+		{method: http.MethodPut, path: "/latest/api/token", expected: http.StatusBadRequest},
+		{method: http.MethodPut, path: "/latest/api/token", header: map[string]string{tokenTTLHeader: "21601"}, expected: http.StatusBadRequest},
+		{method: http.MethodPut, path: "/latest/api/token", header: map[string]string{tokenTTLHeader: "60", "X-Forwarded-For": "10.0.0.1"}, expected: http.StatusForbidden},
+		{method: http.MethodGet, path: "/latest/api/token", header: map[string]string{tokenTTLHeader: "60"}, expected: http.StatusForbidden},
+		{method: http.MethodPut, path: "/latest/api/token", header: map[string]string{tokenTTLHeader: "60"}, expected: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		req, err := http.NewRequest(tt.method, srv.URL+tt.path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for k, v := range tt.header {
+			req.Header.Set(k, v)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode != tt.expected {
+			t.Errorf("%s %s %v: got %d, expected %d", tt.method, tt.path, tt.header, resp.StatusCode, tt.expected)
+		}
+	}
+}
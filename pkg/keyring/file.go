@@ -0,0 +1,183 @@
+package keyring
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// iterations of PBKDF2-HMAC-SHA256 deriving the file key from the passphrase.
+const iterations = 600000
+
+// File stores secrets encrypted with AES-256-GCM in a 0600 JSON file. The key
+// is derived from a passphrase.
+type File struct {
+	Path       string
+	passphrase func() ([]byte, error)
+	key        []byte
+}
+
+type fileContent struct {
+	Salt    []byte
+	Secrets map[string]sealed
+}
+
+type sealed struct {
+	Nonce []byte
+	Data  []byte
+}
+
+// NewFile returns a store in the user config directory,
+// $XDG_CONFIG_HOME/aws-login/secrets.json on Linux.
+func NewFile(passphrase func() ([]byte, error)) (*File, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	return &File{Path: filepath.Join(dir, "aws-login", "secrets.json"), passphrase: passphrase}, nil
+}
+
+func (f *File) Get(name string) (string, error) {
+	content, err := f.read()
+	if err != nil {
+		return "", err
+	}
+
+	s, ok := content.Secrets[name]
+	if !ok {
+		return "", ErrNotFound
+	}
+
+	aead, err := f.aead(content.Salt)
+	if err != nil {
+		return "", err
+	}
+
+	plain, err := aead.Open(nil, s.Nonce, s.Data, []byte(name))
+	if err != nil {
+		return "", errors.New("can not decrypt secret, wrong passphrase?")
+	}
+	return string(plain), nil
+}
+
+func (f *File) Set(name, value string) error {
+	content, err := f.read()
+	if err != nil {
+		return err
+	}
+
+	aead, err := f.aead(content.Salt)
+	if err != nil {
+		return err
+	}
+
+	// refuse to mix secrets encrypted with different passphrases
+	for n, s := range content.Secrets {
+		if _, err := aead.Open(nil, s.Nonce, s.Data, []byte(n)); err != nil {
+			return errors.New("passphrase does not match the existing secrets")
+		}
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	content.Secrets[name] = sealed{Nonce: nonce, Data: aead.Seal(nil, nonce, []byte(value), []byte(name))}
+
+	return f.write(content)
+}
+
+func (f *File) Delete(name string) error {
+	content, err := f.read()
+	if err != nil {
+		return err
+	}
+
+	if _, ok := content.Secrets[name]; !ok {
+		return ErrNotFound
+	}
+	delete(content.Secrets, name)
+
+	return f.write(content)
+}
+
+func (f *File) read() (*fileContent, error) {
+	b, err := os.ReadFile(filepath.Clean(f.Path))
+	if errors.Is(err, os.ErrNotExist) {
+		salt := make([]byte, 16)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, err
+		}
+		return &fileContent{Salt: salt, Secrets: map[string]sealed{}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	content := &fileContent{}
+	if err := json.Unmarshal(b, content); err != nil {
+		return nil, err
+	}
+	if content.Secrets == nil {
+		content.Secrets = map[string]sealed{}
+	}
+	return content, nil
+}
+
+func (f *File) write(content *fileContent) error {
+	if err := os.MkdirAll(filepath.Dir(f.Path), 0700); err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(content)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(f.Path), ".secrets.*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if _, err := tmp.Write(b); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), f.Path)
+}
+
+func (f *File) aead(salt []byte) (cipher.AEAD, error) {
+	if f.key == nil {
+		if f.passphrase == nil {
+			return nil, errors.New("a passphrase is required for the file secret store")
+		}
+
+		passphrase, err := f.passphrase()
+		if err != nil {
+			return nil, err
+		}
+		if len(passphrase) == 0 {
+			return nil, errors.New("empty passphrase")
+		}
+		if f.key, err = pbkdf2.Key(sha256.New, string(passphrase), salt, iterations, 32); err != nil {
+			return nil, err
+		}
+	}
+
+	block, err := aes.NewCipher(f.key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,61 @@
+package keyring
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+)
+
+// Service is the name secrets are stored under in OS secret stores.
+const Service = "aws-login"
+
+// ErrNotFound is returned by Get when there is no secret with the given name.
+var ErrNotFound = errors.New("secret not found")
+
+// Store keeps named secrets.
+type Store interface {
+	Get(name string) (string, error)
+	Set(name, value string) error
+	Delete(name string) error
+}
+
+// Backends lists the names accepted by Open.
+var Backends = []string{"auto", "keychain", "secret-tool", "file"}
+
+// Open returns the named backend. "auto", or an empty name, picks the macOS
+// keychain or the freedesktop secret service when their command line tools are
+// available and falls back to an encrypted file. passphrase is only called by
+// the file backend.
+func Open(backend string, passphrase func() ([]byte, error)) (Store, error) {
+	if backend == "" || backend == "auto" {
+		backend = detect()
+	}
+
+	switch backend {
+	case "keychain":
+		return keychain{}, nil
+	case "secret-tool":
+		return secretTool{}, nil
+	case "file":
+		return NewFile(passphrase)
+	default:
+		return nil, fmt.Errorf("unknown secret store %q", backend)
+	}
+}
+
+func detect() string {
+	if runtime.GOOS == "darwin" && hasCommand("security") {
+		return "keychain"
+	}
+	if runtime.GOOS == "linux" && hasCommand("secret-tool") && os.Getenv("DBUS_SESSION_BUS_ADDRESS") != "" {
+		return "secret-tool"
+	}
+	return "file"
+}
+
+func hasCommand(name string) bool {
+	_, err := exec.LookPath(name)
+	return err == nil
+}
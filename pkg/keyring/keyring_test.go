@@ -0,0 +1,72 @@
+package keyring
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// storeV1 was written with the PBKDF2 implementation aws-login had before
+// using crypto/pbkdf2, the key derivation has to stay the same.
+const storeV1 = `{"Salt":"vUolodvZlHiC7EZcDOQ1ow==","Secrets":{"arn:aws:iam::111111111111:mfa/alice":{"Nonce":"ai7o70VfEUxNCxBu","Data":"qbukTVsnE3cQc0wgrNL8ZKzSppa1bRvAmOidQ2vhlXpUJmuhr86ALgqJpG0+8xz+y3Y7ovkO/jA="}}}`
+
+func TestFileCompatible(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.json")
+	if err := os.WriteFile(path, []byte(storeV1), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	f := &File{Path: path, passphrase: func() ([]byte, error) { return []byte("correct horse"), nil }}
+	got, err := f.Get("arn:aws:iam::111111111111:mfa/alice")
+	if err != nil || got != "otpauth://totp/x?secret=GEZDGNBVGY3TQOJQ" {
+		t.Errorf("got %q, %v", got, err)
+	}
+}
+
+func TestFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "aws-login", "secrets.json")
+	passphrase := func(p string) func() ([]byte, error) {
+		return func() ([]byte, error) { return []byte(p), nil }
+	}
+
+	f := &File{Path: path, passphrase: passphrase("correct")}
+	if _, err := f.Get("serial"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	if err := f.Set("serial", "otpauth://totp/x?secret=ABC"); err != nil {
+		t.Fatal(err)
+	}
+
+	f = &File{Path: path, passphrase: passphrase("correct")}
+	got, err := f.Get("serial")
+	if err != nil || got != "otpauth://totp/x?secret=ABC" {
+		t.Errorf("got %q, %v", got, err)
+	}
+
+	wrong := &File{Path: path, passphrase: passphrase("wrong")}
+	if _, err := wrong.Get("serial"); err == nil {
+		t.Error("expected error with wrong passphrase")
+	}
+	if err := wrong.Set("other", "value"); err == nil {
+		t.Error("expected error adding a secret with a different passphrase")
+	}
+
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != 0600 {
+			t.Errorf("got permissions %v", info.Mode().Perm())
+		}
+	}
+
+	if err := f.Delete("serial"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Get("serial"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound after delete, got %v", err)
+	}
+}
@@ -0,0 +1,86 @@
+package keyring
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// keychain uses the macOS login keychain through the security tool.
+type keychain struct{}
+
+func (keychain) Get(name string) (string, error) {
+	out, err := run(nil, "security", "find-generic-password", "-s", Service, "-a", name, "-w")
+	if err != nil {
+		if strings.Contains(err.Error(), "could not be found") {
+			return "", ErrNotFound
+		}
+		return "", err
+	}
+	return strings.TrimSuffix(out, "\n"), nil
+}
+
+// Set passes the secret on stdin of an interactive security session, so it
+// never shows up in the process list.
+func (keychain) Set(name, value string) error {
+	command := fmt.Sprintf("add-generic-password -U -s %s -a %s -w %s\n", quote(Service), quote(name), quote(value))
+	_, err := run(strings.NewReader(command), "security", "-i")
+	return err
+}
+
+func (keychain) Delete(name string) error {
+	_, err := run(nil, "security", "delete-generic-password", "-s", Service, "-a", name)
+	if err != nil && strings.Contains(err.Error(), "could not be found") {
+		return ErrNotFound
+	}
+	return err
+}
+
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// secretTool uses the freedesktop secret service (GNOME Keyring, KWallet)
+// through secret-tool from libsecret.
+type secretTool struct{}
+
+func (secretTool) Get(name string) (string, error) {
+	out, err := run(nil, "secret-tool", "lookup", "service", Service, "account", name)
+
+	// lookup exits with 1 and no output when nothing matches
+	var exitErr *exec.ExitError
+	if out == "" && (err == nil || errors.As(err, &exitErr)) {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	return out, nil
+}
+
+func (secretTool) Set(name, value string) error {
+	_, err := run(strings.NewReader(value), "secret-tool", "store", "--label", Service+" "+name, "service", Service, "account", name)
+	return err
+}
+
+func (secretTool) Delete(name string) error {
+	_, err := run(nil, "secret-tool", "clear", "service", Service, "account", name)
+	return err
+}
+
+func run(stdin *strings.Reader, name string, args ...string) (string, error) {
+	cmd := exec.Command(name, args...) // #nosec G204 -- fixed tools with fixed subcommands
+	if stdin != nil {
+		cmd.Stdin = stdin
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return stdout.String(), fmt.Errorf("%s: %w: %s", name, err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
@@ -0,0 +1,363 @@
+package login
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awscredentials "github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/aws-sdk-go-v2/service/sts/types"
+
+	"github.com/michalschott/aws-login/pkg/policy"
+)
+
+// STS is the part of the STS API used to log in. *sts.Client implements it.
+type STS interface {
+	GetCallerIdentity(ctx context.Context, params *sts.GetCallerIdentityInput, optFns ...func(*sts.Options)) (*sts.GetCallerIdentityOutput, error)
+	GetSessionToken(ctx context.Context, params *sts.GetSessionTokenInput, optFns ...func(*sts.Options)) (*sts.GetSessionTokenOutput, error)
+	AssumeRole(ctx context.Context, params *sts.AssumeRoleInput, optFns ...func(*sts.Options)) (*sts.AssumeRoleOutput, error)
+}
+
+// FederationSTS is the part of the STS API used by FederationToken.
+// *sts.Client implements it.
+type FederationSTS interface {
+	GetFederationToken(ctx context.Context, params *sts.GetFederationTokenInput, optFns ...func(*sts.Options)) (*sts.GetFederationTokenOutput, error)
+}
+
+// WebIdentitySTS is the part of the STS API used by WebIdentity. *sts.Client
+// implements it.
+type WebIdentitySTS interface {
+	AssumeRoleWithWebIdentity(ctx context.Context, params *sts.AssumeRoleWithWebIdentityInput, optFns ...func(*sts.Options)) (*sts.AssumeRoleWithWebIdentityOutput, error)
+}
+
+// Credentials are temporary credentials returned by STS.
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	Expiration      time.Time
+}
+
+// Provider returns a provider handing out c, for SDK clients acting with
+// them.
+func (c Credentials) Provider() aws.CredentialsProvider {
+	return awscredentials.NewStaticCredentialsProvider(c.AccessKeyID, c.SecretAccessKey, c.SessionToken)
+}
+
+// Identity is the caller as returned by GetCallerIdentity.
+type Identity struct {
+	Account string `json:"account"`
+	Arn     string `json:"arn"`
+	UserID  string `json:"userId"`
+}
+
+// Role is a role to assume.
+type Role struct {
+	Arn         string
+	SessionName string
+	// Duration of the session in seconds
+	Duration   int32
+	ExternalID string
+	// Tags are session tags. Those listed in TransitiveTagKeys are passed on
+	// to roles assumed with the session.
+	Tags              []Tag
+	TransitiveTagKeys []string
+	// SourceIdentity is kept by all sessions of a role chain once set.
+	SourceIdentity string
+	// Policy limits the session to what both the role and the policy allow.
+	Policy policy.Policy
+}
+
+// Tag is a session tag.
+type Tag struct {
+	Key   string
+	Value string
+}
+
+// STS limits of AssumeRole parameters
+const (
+	maxTags           = 50
+	maxTagKey         = 128
+	maxTagValue       = 256
+	maxExternalID     = 1224
+	maxSourceIdentity = 64
+	maxFederatedName  = 32
+)
+
+var (
+	tagPattern            = regexp.MustCompile(`^[\p{L}\p{Z}\p{N}_.:/=+\-@]*$`)
+	externalIDPattern     = regexp.MustCompile(`^[\w+=,.@:/-]*$`)
+	sourceIdentityPattern = regexp.MustCompile(`^[\w+=,.@-]*$`)
+)
+
+// Validate checks the settings of r against the limits of STS, which would
+// otherwise reject the call.
+func (r Role) Validate() error {
+	if len(r.Tags) > maxTags {
+		return fmt.Errorf("%d session tags, at most %d are allowed", len(r.Tags), maxTags)
+	}
+	keys := map[string]bool{}
+	for _, t := range r.Tags {
+		switch {
+		case t.Key == "" || utf8.RuneCountInString(t.Key) > maxTagKey:
+			return fmt.Errorf("tag key %q must have 1 to %d characters", t.Key, maxTagKey)
+		case utf8.RuneCountInString(t.Value) > maxTagValue:
+			return fmt.Errorf("value of tag %s must have at most %d characters", t.Key, maxTagValue)
+		case !tagPattern.MatchString(t.Key) || !tagPattern.MatchString(t.Value):
+			return fmt.Errorf("tag %s=%s has characters other than letters, digits, spaces and _.:/=+-@", t.Key, t.Value)
+		case strings.HasPrefix(strings.ToLower(t.Key), "aws:"):
+			return fmt.Errorf("tag key %s uses the reserved prefix aws:", t.Key)
+		case keys[strings.ToLower(t.Key)]:
+			// tag keys are case insensitive
+			return fmt.Errorf("duplicate tag key %s", t.Key)
+		}
+		keys[strings.ToLower(t.Key)] = true
+	}
+	for _, k := range r.TransitiveTagKeys {
+		if !keys[strings.ToLower(k)] {
+			return fmt.Errorf("transitive tag key %s is not a session tag", k)
+		}
+	}
+
+	if r.ExternalID != "" && (len(r.ExternalID) < 2 || len(r.ExternalID) > maxExternalID || !externalIDPattern.MatchString(r.ExternalID)) {
+		return fmt.Errorf("external ID must have 2 to %d letters, digits or characters of _+=,.@:/-", maxExternalID)
+	}
+	if r.SourceIdentity != "" {
+		if len(r.SourceIdentity) < 2 || len(r.SourceIdentity) > maxSourceIdentity || !sourceIdentityPattern.MatchString(r.SourceIdentity) {
+			return fmt.Errorf("source identity %q must have 2 to %d letters, digits or characters of _+=,.@-", r.SourceIdentity, maxSourceIdentity)
+		}
+		if strings.HasPrefix(strings.ToLower(r.SourceIdentity), "aws:") {
+			return fmt.Errorf("source identity %s uses the reserved prefix aws:", r.SourceIdentity)
+		}
+	}
+	return r.Policy.Check()
+}
+
+// MFA authenticates a call with a code of the device Serial.
+type MFA struct {
+	Serial string
+	// Call runs fn with an MFA code. It may run fn again with a new code
+	// when STS rejects one.
+	Call func(fn func(code string) error) error
+}
+
+// StaticMFA uses a single code of serial.
+func StaticMFA(serial, code string) *MFA {
+	return &MFA{Serial: serial, Call: func(fn func(string) error) error { return fn(code) }}
+}
+
+func (m *MFA) call(fn func(serial, code *string) error) error {
+	if m == nil {
+		return fn(nil, nil)
+	}
+	return m.Call(func(code string) error {
+		return fn(aws.String(m.Serial), aws.String(code))
+	})
+}
+
+// Options describe a login.
+type Options struct {
+	// Client calls STS with the base credentials.
+	Client STS
+	// NewClient returns a client calling STS with credentials. It is needed
+	// to assume every role of a chain after the first one.
+	NewClient func(Credentials) STS
+
+	// Roles are assumed in order, each with the credentials of the previous
+	// one. Without roles a session token is requested.
+	Roles []Role
+	// Duration of the session token in seconds.
+	Duration int32
+	// MFA authenticates the first call, leaving the base credentials.
+	MFA *MFA
+}
+
+// Login requests a session token or assumes the roles of o.
+func Login(ctx context.Context, o Options) (*Credentials, error) {
+	if o.Client == nil {
+		return nil, errors.New("no STS client")
+	}
+	if len(o.Roles) == 0 {
+		return SessionToken(ctx, o.Client, o.Duration, o.MFA)
+	}
+	if len(o.Roles) > 1 && o.NewClient == nil {
+		return nil, errors.New("role chain needs NewClient")
+	}
+
+	for _, role := range o.Roles {
+		if err := role.Validate(); err != nil {
+			return nil, fmt.Errorf("role %s: %w", role.Arn, err)
+		}
+	}
+
+	client, mfa := o.Client, o.MFA
+	var credentials *Credentials
+	for _, role := range o.Roles {
+		var err error
+		credentials, err = AssumeRole(ctx, client, role, mfa)
+		if err != nil {
+			return nil, fmt.Errorf("assuming %s: %w", role.Arn, err)
+		}
+
+		// MFA is only checked when leaving the base credentials
+		mfa = nil
+		if o.NewClient != nil {
+			client = o.NewClient(*credentials)
+		}
+	}
+	return credentials, nil
+}
+
+// ClientFor returns a NewClient function creating STS clients with cfg and
+// optFns.
+func ClientFor(cfg aws.Config, optFns ...func(*sts.Options)) func(Credentials) STS {
+	return func(c Credentials) STS {
+		optFns := append(optFns[:len(optFns):len(optFns)], func(o *sts.Options) {
+			o.Credentials = c.Provider()
+		})
+		return sts.NewFromConfig(cfg, optFns...)
+	}
+}
+
+// CallerIdentity returns the identity of the credentials client calls STS
+// with.
+func CallerIdentity(ctx context.Context, client STS) (*Identity, error) {
+	result, err := client.GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return nil, err
+	}
+	return &Identity{
+		Account: aws.ToString(result.Account),
+		Arn:     aws.ToString(result.Arn),
+		UserID:  aws.ToString(result.UserId),
+	}, nil
+}
+
+// SessionToken requests a session token valid for duration seconds,
+// authenticated with mfa when it is not nil.
+func SessionToken(ctx context.Context, client STS, duration int32, mfa *MFA) (*Credentials, error) {
+	input := &sts.GetSessionTokenInput{DurationSeconds: aws.Int32(duration)}
+
+	var result *sts.GetSessionTokenOutput
+	err := mfa.call(func(serial, code *string) error {
+		input.SerialNumber, input.TokenCode = serial, code
+		var err error
+		result, err = client.GetSessionToken(ctx, input)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return fromSTS(result.Credentials)
+}
+
+// AssumeRole assumes role, authenticated with mfa when it is not nil.
+func AssumeRole(ctx context.Context, client STS, role Role, mfa *MFA) (*Credentials, error) {
+	input := &sts.AssumeRoleInput{
+		RoleArn:         aws.String(role.Arn),
+		RoleSessionName: aws.String(role.SessionName),
+		DurationSeconds: aws.Int32(role.Duration),
+	}
+	if role.ExternalID != "" {
+		input.ExternalId = aws.String(role.ExternalID)
+	}
+	if role.SourceIdentity != "" {
+		input.SourceIdentity = aws.String(role.SourceIdentity)
+	}
+	for _, t := range role.Tags {
+		input.Tags = append(input.Tags, types.Tag{Key: aws.String(t.Key), Value: aws.String(t.Value)})
+	}
+	input.TransitiveTagKeys = role.TransitiveTagKeys
+	input.Policy, input.PolicyArns = policyInput(role.Policy)
+
+	var result *sts.AssumeRoleOutput
+	err := mfa.call(func(serial, code *string) error {
+		input.SerialNumber, input.TokenCode = serial, code
+		var err error
+		result, err = client.AssumeRole(ctx, input)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return fromSTS(result.Credentials)
+}
+
+// WebIdentity assumes role with an OIDC token. The call needs no
+// credentials, session tags and the source identity are taken from the
+// token.
+func WebIdentity(ctx context.Context, client WebIdentitySTS, role Role, token string) (*Credentials, error) {
+	if len(role.Tags) > 0 || role.SourceIdentity != "" || role.ExternalID != "" {
+		return nil, errors.New("session tags, source identity and external ID are set by the web identity token")
+	}
+	if err := role.Validate(); err != nil {
+		return nil, err
+	}
+
+	input := &sts.AssumeRoleWithWebIdentityInput{
+		RoleArn:          aws.String(role.Arn),
+		RoleSessionName:  aws.String(role.SessionName),
+		DurationSeconds:  aws.Int32(role.Duration),
+		WebIdentityToken: aws.String(token),
+	}
+	input.Policy, input.PolicyArns = policyInput(role.Policy)
+	result, err := client.AssumeRoleWithWebIdentity(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("assuming %s: %w", role.Arn, err)
+	}
+	return fromSTS(result.Credentials)
+}
+
+// FederationToken requests credentials of the federated user name, limited
+// to p. It needs long term credentials of an IAM user and does not support
+// MFA.
+func FederationToken(ctx context.Context, client FederationSTS, name string, duration int32, p policy.Policy) (*Credentials, error) {
+	if len(name) < 2 || len(name) > maxFederatedName || !sourceIdentityPattern.MatchString(name) {
+		return nil, fmt.Errorf("federated user name %q must have 2 to %d letters, digits or characters of _+=,.@-", name, maxFederatedName)
+	}
+	if p.IsZero() {
+		// federated users without a policy are allowed nothing
+		return nil, errors.New("federation token needs a session policy")
+	}
+	if err := p.Check(); err != nil {
+		return nil, err
+	}
+
+	input := &sts.GetFederationTokenInput{Name: aws.String(name), DurationSeconds: aws.Int32(duration)}
+	input.Policy, input.PolicyArns = policyInput(p)
+	result, err := client.GetFederationToken(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	return fromSTS(result.Credentials)
+}
+
+func policyInput(p policy.Policy) (*string, []types.PolicyDescriptorType) {
+	var document *string
+	if p.Document != "" {
+		document = aws.String(p.Document)
+	}
+	var arns []types.PolicyDescriptorType
+	for _, arn := range p.Arns {
+		arns = append(arns, types.PolicyDescriptorType{Arn: aws.String(arn)})
+	}
+	return document, arns
+}
+
+func fromSTS(c *types.Credentials) (*Credentials, error) {
+	if c == nil {
+		return nil, errors.New("STS returned no credentials")
+	}
+	return &Credentials{
+		AccessKeyID:     aws.ToString(c.AccessKeyId),
+		SecretAccessKey: aws.ToString(c.SecretAccessKey),
+		SessionToken:    aws.ToString(c.SessionToken),
+		Expiration:      aws.ToTime(c.Expiration),
+	}, nil
+}
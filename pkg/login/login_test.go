@@ -0,0 +1,281 @@
+package login_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+
+	"github.com/michalschott/aws-login/pkg/login"
+	"github.com/michalschott/aws-login/pkg/login/logintest"
+	"github.com/michalschott/aws-login/pkg/policy"
+)
+
+func TestLogin(t *testing.T) {
+	admin := login.Role{Arn: "arn:aws:iam::222222222222:role/admin", SessionName: "alice", Duration: 3600}
+	workload := login.Role{Arn: "arn:aws:iam::333333333333:role/workload", SessionName: "alice", Duration: 900}
+
+	tests := []struct {
+		name    string
+		roles   []login.Role
+		mfa     *login.MFA
+		mfaCode string
+		want    []logintest.Call
+		wantKey string
+		wantErr bool
+	}{
+		{
+			name:    "session token",
+			want:    []logintest.Call{{Action: "GetSessionToken", Duration: 43200}},
+			wantKey: "ASIAFAKE1",
+		},
+		{
+			name:    "session token with MFA",
+			mfa:     login.StaticMFA("arn:aws:iam::111111111111:mfa/alice", "123456"),
+			mfaCode: "123456",
+			want:    []logintest.Call{{Action: "GetSessionToken", Duration: 43200, TokenCode: "123456"}},
+			wantKey: "ASIAFAKE1",
+		},
+		{
+			name:    "wrong MFA code",
+			mfa:     login.StaticMFA("arn:aws:iam::111111111111:mfa/alice", "000000"),
+			mfaCode: "123456",
+			want:    []logintest.Call{{Action: "GetSessionToken", Duration: 43200, TokenCode: "000000"}},
+			wantErr: true,
+		},
+		{
+			name:    "role chain with MFA on the first hop",
+			roles:   []login.Role{admin, workload},
+			mfa:     login.StaticMFA("arn:aws:iam::111111111111:mfa/alice", "123456"),
+			mfaCode: "123456",
+			want: []logintest.Call{
+				{Action: "AssumeRole", RoleArn: admin.Arn, Duration: 3600, TokenCode: "123456"},
+				{Action: "AssumeRole", AccessKeyID: "ASIAFAKE1", RoleArn: workload.Arn, Duration: 900},
+			},
+			wantKey: "ASIAFAKE2",
+		},
+	}
+
+	for _, tt := range tests {
+		fake := logintest.New()
+		fake.MfaCode = tt.mfaCode
+
+		c, err := login.Login(context.Background(), login.Options{
+			Client:    fake.Client(),
+			NewClient: fake.NewClient,
+			Roles:     tt.roles,
+			Duration:  43200,
+			MFA:       tt.mfa,
+		})
+		if (err != nil) != tt.wantErr {
+			t.Errorf("err is wrong, value=%v, wantErr=%v, err=%v", tt.name, tt.wantErr, err)
+			continue
+		}
+		if !reflect.DeepEqual(fake.Calls, tt.want) {
+			t.Errorf("%s: calls are %+v, want %+v", tt.name, fake.Calls, tt.want)
+		}
+		if err == nil && (c.AccessKeyID != tt.wantKey || c.Expiration.IsZero()) {
+			t.Errorf("%s: got credentials %+v", tt.name, c)
+		}
+	}
+}
+
+func TestLoginRetriesMfa(t *testing.T) {
+	fake := logintest.New()
+	fake.MfaCode = "123456"
+
+	codes := []string{"000000", "123456"}
+	mfa := &login.MFA{
+		Serial: "arn:aws:iam::111111111111:mfa/alice",
+		Call: func(fn func(string) error) error {
+			var err error
+			for _, code := range codes {
+				if err = fn(code); err == nil {
+					return nil
+				}
+			}
+			return err
+		},
+	}
+
+	c, err := login.Login(context.Background(), login.Options{Client: fake.Client(), Duration: 3600, MFA: mfa})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.AccessKeyID != "ASIAFAKE1" || len(fake.Calls) != 2 {
+		t.Errorf("got %+v after calls %+v", c, fake.Calls)
+	}
+}
+
+func TestCallerIdentity(t *testing.T) {
+	fake := logintest.New()
+	id, err := login.CallerIdentity(context.Background(), fake.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id.Account != "111111111111" || id.Arn != "arn:aws:iam::111111111111:user/alice" {
+		t.Errorf("got %+v", id)
+	}
+
+	fake.Err = errors.New("no network")
+	if _, err := login.CallerIdentity(context.Background(), fake.Client()); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestLoginChainNeedsNewClient(t *testing.T) {
+	fake := logintest.New()
+	_, err := login.Login(context.Background(), login.Options{
+		Client: fake.Client(),
+		Roles:  []login.Role{{Arn: "arn:aws:iam::1:role/a"}, {Arn: "arn:aws:iam::1:role/b"}},
+	})
+	if err == nil || len(fake.Calls) != 0 {
+		t.Errorf("expected an error before calling STS, err=%v, calls=%v", err, fake.Calls)
+	}
+}
+
+func TestClientFor(t *testing.T) {
+	var gotAction, gotKey string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		gotAction, gotKey = r.Form.Get("Action"), r.Header.Get("Authorization")
+		w.Header().Set("Content-Type", "text/xml")
+		_, _ = w.Write([]byte(`<GetCallerIdentityResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/"><GetCallerIdentityResult>` +
+			`<Arn>arn:aws:sts::222222222222:assumed-role/admin/alice</Arn><UserId>AROAFAKE:alice</UserId><Account>222222222222</Account>` +
+			`</GetCallerIdentityResult></GetCallerIdentityResponse>`))
+	}))
+	defer srv.Close()
+
+	cfg := aws.Config{Region: "us-east-1", HTTPClient: srv.Client()}
+	newClient := login.ClientFor(cfg, func(o *sts.Options) { o.BaseEndpoint = aws.String(srv.URL) })
+
+	id, err := login.CallerIdentity(context.Background(), newClient(login.Credentials{AccessKeyID: "ASIAFAKE1", SecretAccessKey: "secret1", SessionToken: "token1"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id.Account != "222222222222" || gotAction != "GetCallerIdentity" {
+		t.Errorf("got %+v for action %q", id, gotAction)
+	}
+	if !strings.Contains(gotKey, "Credential=ASIAFAKE1/") {
+		t.Errorf("request not signed with the given credentials: %q", gotKey)
+	}
+}
+
+func TestLoginSessionSettings(t *testing.T) {
+	fake := logintest.New()
+	role := login.Role{
+		Arn:               "arn:aws:iam::222222222222:role/admin",
+		SessionName:       "alice",
+		Duration:          3600,
+		ExternalID:        "secret",
+		Tags:              []login.Tag{{Key: "team", Value: "platform"}, {Key: "project", Value: "login"}},
+		TransitiveTagKeys: []string{"project"},
+		SourceIdentity:    "alice",
+		Policy:            policy.Policy{Document: `{"Statement":{"Effect":"Allow","Action":"s3:*","Resource":"*"}}`},
+	}
+	if _, err := login.Login(context.Background(), login.Options{Client: fake.Client(), Roles: []login.Role{role}}); err != nil {
+		t.Fatal(err)
+	}
+	want := []logintest.Call{{
+		Action:            "AssumeRole",
+		RoleArn:           role.Arn,
+		Duration:          3600,
+		ExternalID:        "secret",
+		SourceIdentity:    "alice",
+		Tags:              []string{"team=platform", "project=login"},
+		TransitiveTagKeys: []string{"project"},
+		Policy:            role.Policy.Document,
+	}}
+	if !reflect.DeepEqual(fake.Calls, want) {
+		t.Errorf("calls are %+v, want %+v", fake.Calls, want)
+	}
+}
+
+func TestRoleValidate(t *testing.T) {
+	many := []login.Tag{}
+	for i := 0; i < 51; i++ {
+		many = append(many, login.Tag{Key: fmt.Sprintf("k%d", i)})
+	}
+
+	tests := []struct {
+		role    login.Role
+		wantErr bool
+	}{
+		{role: login.Role{Tags: []login.Tag{{Key: "cost center", Value: "ünïcode:/=+-@"}}}},
+		{role: login.Role{Tags: many}, wantErr: true},
+		{role: login.Role{Tags: []login.Tag{{Key: strings.Repeat("k", 129)}}}, wantErr: true},
+		{role: login.Role{Tags: []login.Tag{{Key: "k", Value: strings.Repeat("v", 257)}}}, wantErr: true},
+		{role: login.Role{Tags: []login.Tag{{Key: "k"}}, TransitiveTagKeys: []string{"other"}}, wantErr: true},
+		{role: login.Role{ExternalID: strings.Repeat("x", 1225)}, wantErr: true},
+		{role: login.Role{SourceIdentity: "alice@example.com"}},
+		{role: login.Role{SourceIdentity: "aws:alice"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		if err := tt.role.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("err is wrong, value=%+v, wantErr=%v, err=%v", tt.role, tt.wantErr, err)
+		}
+	}
+}
+
+func TestFederationToken(t *testing.T) {
+	readOnly := policy.Policy{Arns: []string{"arn:aws:iam::aws:policy/ReadOnlyAccess"}}
+
+	tests := []struct {
+		name    string
+		policy  policy.Policy
+		want    []logintest.Call
+		wantErr bool
+	}{
+		{
+			name:   "alice",
+			policy: readOnly,
+			want:   []logintest.Call{{Action: "GetFederationToken", Duration: 3600, Name: "alice", PolicyArns: readOnly.Arns}},
+		},
+		{name: "alice", wantErr: true},
+		{name: "a", policy: readOnly, wantErr: true},
+		{name: "alice", policy: policy.Policy{Document: `{"Statement":`}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		fake := logintest.New()
+		c, err := login.FederationToken(context.Background(), fake.Client(), tt.name, 3600, tt.policy)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("err is wrong, value=%+v, wantErr=%v, err=%v", tt.policy, tt.wantErr, err)
+			continue
+		}
+		if !reflect.DeepEqual(fake.Calls, tt.want) {
+			t.Errorf("calls are %+v, want %+v", fake.Calls, tt.want)
+		}
+		if err == nil && c.AccessKeyID != "ASIAFAKE1" {
+			t.Errorf("got credentials %+v", c)
+		}
+	}
+}
+
+func TestWebIdentity(t *testing.T) {
+	role := login.Role{Arn: "arn:aws:iam::111111111111:role/deploy", SessionName: "ci", Duration: 900}
+
+	fake := logintest.New()
+	fake.MfaCode = "123456"
+	c, err := login.WebIdentity(context.Background(), fake.Client(), role, "header.payload.signature")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []logintest.Call{{Action: "AssumeRoleWithWebIdentity", RoleArn: role.Arn, Duration: 900, WebIdentityToken: "header.payload.signature"}}
+	if !reflect.DeepEqual(fake.Calls, want) || c.AccessKeyID != "ASIAFAKE1" {
+		t.Errorf("got %+v after calls %+v", c, fake.Calls)
+	}
+
+	role.Tags = []login.Tag{{Key: "team", Value: "platform"}}
+	if _, err := login.WebIdentity(context.Background(), fake.Client(), role, "token"); err == nil {
+		t.Error("expected an error for session tags")
+	}
+}
@@ -0,0 +1,223 @@
+package logintest
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/aws-sdk-go-v2/service/sts/types"
+	"github.com/aws/smithy-go"
+
+	"github.com/michalschott/aws-login/pkg/login"
+)
+
+// STS is a fake STS API for tests of code using package login. Every call
+// issues new numbered credentials and is recorded in Calls.
+type STS struct {
+	// Account and Arn are returned by GetCallerIdentity.
+	Account string
+	Arn     string
+	// MfaCode is the only code accepted when set. Calls without a code are
+	// then denied as well.
+	MfaCode string
+	// Err, when set, is returned by every call.
+	Err error
+
+	mu     sync.Mutex
+	issued int
+	Calls  []Call
+}
+
+// Call is a recorded STS call.
+type Call struct {
+	Action string
+	// AccessKeyID is the key the call was made with, empty for the base
+	// credentials.
+	AccessKeyID string
+	RoleArn     string
+	Duration    int32
+	TokenCode   string
+	// ExternalID, SourceIdentity, Tags as KEY=VALUE and TransitiveTagKeys
+	// are set by AssumeRole.
+	ExternalID        string
+	SourceIdentity    string
+	Tags              []string
+	TransitiveTagKeys []string
+	// Name of the federated user, Policy and PolicyArns are set by
+	// GetFederationToken and, except for Name, AssumeRole.
+	Name       string
+	Policy     string
+	PolicyArns []string
+	// WebIdentityToken is set by AssumeRoleWithWebIdentity.
+	WebIdentityToken string
+}
+
+// New returns a fake for the IAM user alice in account 111111111111.
+func New() *STS {
+	return &STS{Account: "111111111111", Arn: "arn:aws:iam::111111111111:user/alice"}
+}
+
+// Client returns the fake acting with the base credentials.
+func (s *STS) Client() *Client {
+	return &Client{s: s}
+}
+
+// NewClient returns the fake acting with c, for login.Options.NewClient.
+func (s *STS) NewClient(c login.Credentials) login.STS {
+	return &Client{s: s, accessKeyID: c.AccessKeyID}
+}
+
+// Client is the fake acting with a set of credentials. Besides login.STS it
+// implements login.FederationSTS and login.WebIdentitySTS.
+type Client struct {
+	s           *STS
+	accessKeyID string
+}
+
+func (c *Client) GetCallerIdentity(ctx context.Context, params *sts.GetCallerIdentityInput, optFns ...func(*sts.Options)) (*sts.GetCallerIdentityOutput, error) {
+	if _, err := c.s.record(Call{Action: "GetCallerIdentity", AccessKeyID: c.accessKeyID}); err != nil {
+		return nil, err
+	}
+	return &sts.GetCallerIdentityOutput{
+		Account: aws.String(c.s.Account),
+		Arn:     aws.String(c.s.Arn),
+		UserId:  aws.String("AIDAFAKE"),
+	}, nil
+}
+
+func (c *Client) GetSessionToken(ctx context.Context, params *sts.GetSessionTokenInput, optFns ...func(*sts.Options)) (*sts.GetSessionTokenOutput, error) {
+	credentials, err := c.s.record(Call{
+		Action:      "GetSessionToken",
+		AccessKeyID: c.accessKeyID,
+		Duration:    aws.ToInt32(params.DurationSeconds),
+		TokenCode:   aws.ToString(params.TokenCode),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &sts.GetSessionTokenOutput{Credentials: credentials}, nil
+}
+
+func (c *Client) AssumeRole(ctx context.Context, params *sts.AssumeRoleInput, optFns ...func(*sts.Options)) (*sts.AssumeRoleOutput, error) {
+	call := Call{
+		Action:            "AssumeRole",
+		AccessKeyID:       c.accessKeyID,
+		RoleArn:           aws.ToString(params.RoleArn),
+		Duration:          aws.ToInt32(params.DurationSeconds),
+		TokenCode:         aws.ToString(params.TokenCode),
+		ExternalID:        aws.ToString(params.ExternalId),
+		SourceIdentity:    aws.ToString(params.SourceIdentity),
+		TransitiveTagKeys: params.TransitiveTagKeys,
+		Policy:            aws.ToString(params.Policy),
+		PolicyArns:        policyArns(params.PolicyArns),
+	}
+	for _, t := range params.Tags {
+		call.Tags = append(call.Tags, aws.ToString(t.Key)+"="+aws.ToString(t.Value))
+	}
+	credentials, err := c.s.record(call)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sts.AssumeRoleOutput{
+		Credentials: credentials,
+		AssumedRoleUser: &types.AssumedRoleUser{
+			Arn:           aws.String(roleSessionArn(aws.ToString(params.RoleArn), aws.ToString(params.RoleSessionName))),
+			AssumedRoleId: aws.String("AROAFAKE:" + aws.ToString(params.RoleSessionName)),
+		},
+	}, nil
+}
+
+func (c *Client) GetFederationToken(ctx context.Context, params *sts.GetFederationTokenInput, optFns ...func(*sts.Options)) (*sts.GetFederationTokenOutput, error) {
+	name := aws.ToString(params.Name)
+	credentials, err := c.s.record(Call{
+		Action:      "GetFederationToken",
+		AccessKeyID: c.accessKeyID,
+		Duration:    aws.ToInt32(params.DurationSeconds),
+		Name:        name,
+		Policy:      aws.ToString(params.Policy),
+		PolicyArns:  policyArns(params.PolicyArns),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &sts.GetFederationTokenOutput{
+		Credentials: credentials,
+		FederatedUser: &types.FederatedUser{
+			Arn:             aws.String(fmt.Sprintf("arn:aws:sts::%s:federated-user/%s", c.s.Account, name)),
+			FederatedUserId: aws.String(c.s.Account + ":" + name),
+		},
+	}, nil
+}
+
+// AssumeRoleWithWebIdentity accepts any token. Like STS it is called without
+// credentials, so it is never denied for a wrong MFA code.
+func (c *Client) AssumeRoleWithWebIdentity(ctx context.Context, params *sts.AssumeRoleWithWebIdentityInput, optFns ...func(*sts.Options)) (*sts.AssumeRoleWithWebIdentityOutput, error) {
+	credentials, err := c.s.record(Call{
+		Action:           "AssumeRoleWithWebIdentity",
+		AccessKeyID:      c.accessKeyID,
+		RoleArn:          aws.ToString(params.RoleArn),
+		Duration:         aws.ToInt32(params.DurationSeconds),
+		Policy:           aws.ToString(params.Policy),
+		PolicyArns:       policyArns(params.PolicyArns),
+		WebIdentityToken: aws.ToString(params.WebIdentityToken),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &sts.AssumeRoleWithWebIdentityOutput{
+		Credentials: credentials,
+		AssumedRoleUser: &types.AssumedRoleUser{
+			Arn:           aws.String(roleSessionArn(aws.ToString(params.RoleArn), aws.ToString(params.RoleSessionName))),
+			AssumedRoleId: aws.String("AROAFAKE:" + aws.ToString(params.RoleSessionName)),
+		},
+	}, nil
+}
+
+func policyArns(descriptors []types.PolicyDescriptorType) []string {
+	var arns []string
+	for _, d := range descriptors {
+		arns = append(arns, aws.ToString(d.Arn))
+	}
+	return arns
+}
+
+// roleSessionArn returns the ARN of a session of role.
+func roleSessionArn(role, sessionName string) string {
+	account, name := "", role
+	if parts := strings.SplitN(role, ":", 6); len(parts) == 6 {
+		account, name = parts[4], strings.TrimPrefix(parts[5], "role/")
+	}
+	return fmt.Sprintf("arn:aws:sts::%s:assumed-role/%s/%s", account, name, sessionName)
+}
+
+// record stores call and returns the credentials it issues.
+func (s *STS) record(call Call) (*types.Credentials, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Calls = append(s.Calls, call)
+	if s.Err != nil {
+		return nil, s.Err
+	}
+	// MFA is only checked when leaving the base credentials
+	if s.MfaCode != "" && call.AccessKeyID == "" && call.Action != "GetCallerIdentity" && call.Action != "AssumeRoleWithWebIdentity" && call.TokenCode != s.MfaCode {
+		return nil, &smithy.GenericAPIError{Code: "AccessDenied", Message: "MultiFactorAuthentication failed with invalid MFA one time pass code."}
+	}
+
+	s.issued++
+	duration := call.Duration
+	if duration == 0 {
+		duration = 3600
+	}
+	return &types.Credentials{
+		AccessKeyId:     aws.String(fmt.Sprintf("ASIAFAKE%d", s.issued)),
+		SecretAccessKey: aws.String(fmt.Sprintf("secret%d", s.issued)),
+		SessionToken:    aws.String(fmt.Sprintf("token%d", s.issued)),
+		Expiration:      aws.Time(time.Now().Add(time.Duration(duration) * time.Second).Truncate(time.Second)),
+	}, nil
+}
@@ -0,0 +1,77 @@
+package picker
+
+import (
+	"sort"
+	"strings"
+)
+
+// Item is a choice offered by the picker.
+type Item struct {
+	// Key identifies the item in the list of recent choices
+	Key   string
+	Label string
+}
+
+// Match reports whether every word of query occurs in text as a subsequence,
+// ignoring case. Lower scores are better matches, with fewer characters
+// between the matched ones.
+func Match(query, text string) (int, bool) {
+	text = strings.ToLower(text)
+	score := 0
+	for _, word := range strings.Fields(strings.ToLower(query)) {
+		s, ok := matchWord(word, text)
+		if !ok {
+			return 0, false
+		}
+		score += s
+	}
+	return score, true
+}
+
+// matchWord returns the best score of word against every start position.
+func matchWord(word, text string) (int, bool) {
+	best, found := 0, false
+	for start := 0; start < len(text); start++ {
+		if text[start] != word[0] {
+			continue
+		}
+
+		score, i := 0, 1
+		for j := start + 1; j < len(text) && i < len(word); j++ {
+			if text[j] == word[i] {
+				i++
+			} else {
+				score++
+			}
+		}
+		if i == len(word) && (!found || score < best) {
+			best, found = score, true
+		}
+	}
+	return best, found
+}
+
+// Filter returns the items matching query, best matches first. Items with the
+// same score keep their order.
+func Filter(items []Item, query string) []Item {
+	type scored struct {
+		item  Item
+		score int
+	}
+
+	matches := []scored{}
+	for _, item := range items {
+		if score, ok := Match(query, item.Label); ok {
+			matches = append(matches, scored{item, score})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].score < matches[j].score
+	})
+
+	filtered := make([]Item, len(matches))
+	for i, m := range matches {
+		filtered[i] = m.item
+	}
+	return filtered
+}
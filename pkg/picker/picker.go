@@ -0,0 +1,191 @@
+package picker
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+
+	"github.com/michalschott/aws-login/pkg/prompt"
+)
+
+// ErrCanceled is returned by Pick when the user aborts with Esc or Ctrl-C.
+var ErrCanceled = errors.New("canceled")
+
+// visible is how many items are shown at once.
+const visible = 10
+
+// Interactive reports whether there is a terminal to show the picker on.
+func Interactive() bool {
+	f, err := prompt.Terminal()
+	if err != nil {
+		return false
+	}
+	defer func() { _ = f.Close() }()
+	return term.IsTerminal(int(f.Fd())) // #nosec G115 -- file descriptors fit into int
+}
+
+// Pick shows items on the terminal, filtered by what is typed, and returns
+// the one chosen with the arrow keys and Enter.
+func Pick(items []Item, title string) (Item, error) {
+	f, err := prompt.Terminal()
+	if err != nil {
+		return Item{}, err
+	}
+	defer func() { _ = f.Close() }()
+
+	out, err := prompt.Output()
+	if err != nil {
+		return Item{}, err
+	}
+	defer func() { _ = out.Close() }()
+
+	fd := int(f.Fd()) // #nosec G115 -- file descriptors fit into int
+	old, err := term.MakeRaw(fd)
+	if err != nil {
+		return Item{}, err
+	}
+	defer func() { _ = term.Restore(fd, old) }()
+
+	width, _, err := term.GetSize(int(out.Fd())) // #nosec G115 -- file descriptors fit into int
+	if err != nil || width <= 0 {
+		width = 80
+	}
+
+	s := newState(items)
+	buf := make([]byte, 256)
+	for {
+		if err := s.render(out, title, width); err != nil {
+			return Item{}, fmt.Errorf("can not draw the picker: %w", err)
+		}
+
+		n, err := f.Read(buf)
+		if err != nil {
+			_ = s.clear(out)
+			return Item{}, err
+		}
+
+		done, err := s.key(buf[:n])
+		if err != nil || done {
+			if clearErr := s.clear(out); err == nil {
+				err = clearErr
+			}
+			if err != nil {
+				return Item{}, err
+			}
+			return s.matches[s.cursor], nil
+		}
+	}
+}
+
+// state is the query and selection of the picker.
+type state struct {
+	items   []Item
+	query   string
+	matches []Item
+	cursor  int
+}
+
+func newState(items []Item) *state {
+	s := &state{items: items}
+	s.filter()
+	return s
+}
+
+func (s *state) filter() {
+	s.matches = Filter(s.items, s.query)
+	s.cursor = 0
+}
+
+func (s *state) move(delta int) {
+	if len(s.matches) > 0 {
+		s.cursor = (s.cursor + delta + len(s.matches)) % len(s.matches)
+	}
+}
+
+// key handles input read from the terminal in raw mode. It reports whether an
+// item was chosen.
+func (s *state) key(b []byte) (bool, error) {
+	switch {
+	case len(b) == 0:
+	case len(b) >= 3 && b[0] == 0x1b && b[1] == '[':
+		switch b[2] {
+		case 'A':
+			s.move(-1)
+		case 'B':
+			s.move(1)
+		}
+	case b[0] == 0x1b, b[0] == 0x03: // Esc, Ctrl-C
+		return false, ErrCanceled
+	case b[0] == '\r', b[0] == '\n':
+		return len(s.matches) > 0, nil
+	case b[0] == 0x7f, b[0] == 0x08: // Backspace
+		if _, size := utf8.DecodeLastRuneInString(s.query); size > 0 {
+			s.query = s.query[:len(s.query)-size]
+			s.filter()
+		}
+	case b[0] == 0x15: // Ctrl-U
+		s.query = ""
+		s.filter()
+	case b[0] == 0x10: // Ctrl-P
+		s.move(-1)
+	case b[0] == 0x0e: // Ctrl-N
+		s.move(1)
+	case printable(b):
+		s.query += string(b)
+		s.filter()
+	}
+	return false, nil
+}
+
+func printable(b []byte) bool {
+	for _, c := range b {
+		if c < 0x20 || c == 0x7f {
+			return false
+		}
+	}
+	return utf8.Valid(b)
+}
+
+// render draws the query line and the visible matches, leaving the cursor
+// after the query.
+func (s *state) render(w io.Writer, title string, width int) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "\r\x1b[J%s (%d/%d): %s", title, len(s.matches), len(s.items), s.query)
+	column := utf8.RuneCountInString(b.String()) - len("\r\x1b[J")
+
+	first := max(0, s.cursor-visible+1)
+	last := min(len(s.matches), first+visible)
+	for i := first; i < last; i++ {
+		marker := "  "
+		if i == s.cursor {
+			marker = "> "
+		}
+		b.WriteString("\r\n" + truncate(marker+s.matches[i].Label, width-1))
+	}
+
+	if lines := last - first; lines > 0 {
+		fmt.Fprintf(&b, "\x1b[%dA", lines)
+	}
+	b.WriteString("\r")
+	if column > 0 {
+		fmt.Fprintf(&b, "\x1b[%dC", column)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func (s *state) clear(w io.Writer) error {
+	_, err := io.WriteString(w, "\r\x1b[J")
+	return err
+}
+
+func truncate(s string, width int) string {
+	if width <= 0 || utf8.RuneCountInString(s) <= width {
+		return s
+	}
+	return string([]rune(s)[:width])
+}
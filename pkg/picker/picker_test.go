@@ -0,0 +1,116 @@
+package picker
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var items = []Item{
+	{Key: "a", Label: "production (111111111111) Admin"},
+	{Key: "b", Label: "production (111111111111) ReadOnly"},
+	{Key: "c", Label: "staging (222222222222) Developer"},
+	{Key: "d", Label: "sandbox (333333333333) Admin"},
+}
+
+func keys(items []Item) string {
+	k := []string{}
+	for _, item := range items {
+		k = append(k, item.Key)
+	}
+	return strings.Join(k, ",")
+}
+
+func TestFilter(t *testing.T) {
+	tests := []struct {
+		query    string
+		expected string
+	}{
+		{query: "", expected: "a,b,c,d"},
+		{query: "admin", expected: "a,d"},
+		{query: "ADMIN prod", expected: "a"},
+		{query: "2222", expected: "c"},
+		{query: "sbx", expected: "d"},
+		{query: "ro", expected: "a,b"},
+		{query: "xyz", expected: ""},
+	}
+
+	for _, tt := range tests {
+		if got := keys(Filter(items, tt.query)); got != tt.expected {
+			t.Errorf("Filter(%q) = %s, expected %s", tt.query, got, tt.expected)
+		}
+	}
+}
+
+func TestRecent(t *testing.T) {
+	r := &Recent{Path: filepath.Join(t.TempDir(), "aws-login", "recent.json")}
+
+	recent, err := r.Load()
+	if err != nil || len(recent) != 0 {
+		t.Fatalf("got %v, %v without a file", recent, err)
+	}
+
+	for _, key := range []string{"c", "a", "c", "d"} {
+		if err := r.Add(key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	recent, err = r.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(recent, []string{"d", "c", "a"}) {
+		t.Errorf("got %v", recent)
+	}
+
+	sorted := append([]Item{}, items...)
+	SortRecent(sorted, recent)
+	if got := keys(sorted); got != "d,c,a,b" {
+		t.Errorf("got %s", got)
+	}
+}
+
+func TestStateKey(t *testing.T) {
+	s := newState(items)
+
+	for _, input := range []string{"a", "d", "x", "\x7f", "\x1b[B"} {
+		if done, err := s.key([]byte(input)); done || err != nil {
+			t.Fatalf("input %q: got %v, %v", input, done, err)
+		}
+	}
+	if s.query != "ad" || s.matches[s.cursor].Key != "b" {
+		t.Errorf("got query %q and %s selected", s.query, s.matches[s.cursor].Key)
+	}
+
+	if done, err := s.key([]byte("\r")); !done || err != nil {
+		t.Errorf("enter: got %v, %v", done, err)
+	}
+
+	if _, err := s.key([]byte("\x03")); err != ErrCanceled {
+		t.Errorf("ctrl-c: got %v", err)
+	}
+
+	// enter does nothing without a match
+	_, _ = s.key([]byte("zzz"))
+	if done, _ := s.key([]byte("\r")); done {
+		t.Error("enter without matches should not choose")
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("console input can not be written")
+}
+
+func TestRenderError(t *testing.T) {
+	s := newState(items)
+	if err := s.render(failingWriter{}, "Role", 80); err == nil {
+		t.Error("expected the write error to be returned")
+	}
+	if err := s.clear(failingWriter{}); err == nil {
+		t.Error("expected the write error to be returned")
+	}
+}
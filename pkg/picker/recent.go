@@ -0,0 +1,79 @@
+package picker
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// maxRecent is how many recent choices are remembered.
+const maxRecent = 20
+
+// Recent remembers the keys of recent choices in a JSON file, most recent
+// first.
+type Recent struct {
+	Path string
+}
+
+// Load returns the keys of recent choices.
+func (r *Recent) Load() ([]string, error) {
+	b, err := os.ReadFile(filepath.Clean(r.Path))
+	if errors.Is(err, os.ErrNotExist) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return []string{}, err
+	}
+
+	keys := []string{}
+	if err := json.Unmarshal(b, &keys); err != nil {
+		return []string{}, err
+	}
+	return keys, nil
+}
+
+// Add moves key to the front of the recent choices.
+func (r *Recent) Add(key string) error {
+	keys, err := r.Load()
+	if err != nil {
+		keys = []string{}
+	}
+
+	updated := []string{key}
+	for _, k := range keys {
+		if k != key && len(updated) < maxRecent {
+			updated = append(updated, k)
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(r.Path), 0700); err != nil {
+		return err
+	}
+	b, err := json.Marshal(updated)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(r.Path, b, 0600)
+}
+
+// SortRecent moves recently chosen items to the front, most recent first.
+func SortRecent(items []Item, recent []string) {
+	rank := map[string]int{}
+	for i, key := range recent {
+		rank[key] = i + 1
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		ri, rj := rank[items[i].Key], rank[items[j].Key]
+		switch {
+		case ri == 0:
+			return false
+		case rj == 0:
+			return true
+		default:
+			return ri < rj
+		}
+	})
+}
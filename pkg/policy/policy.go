@@ -0,0 +1,203 @@
+package policy
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// STS limits of session policies
+const (
+	// MaxSize is the number of characters of an inline policy without
+	// whitespace. STS additionally limits the compressed size of the policy,
+	// managed policy ARNs and session tags together, which can not be
+	// checked locally and fails with PackedPolicyTooLarge.
+	MaxSize = 2048
+	// MaxArns is the number of managed policies of a session.
+	MaxArns = 10
+)
+
+var arnPattern = regexp.MustCompile(`^arn:[a-z-]+:iam::(aws|\d{12}):policy/[\w+=,.@/-]+$`)
+
+// Policy is a session policy, an inline document and managed policy ARNs.
+// The session is allowed what both its role and the policy allow.
+type Policy struct {
+	Document string
+	Arns     []string
+}
+
+// IsZero reports whether p does not restrict the session.
+func (p Policy) IsZero() bool {
+	return p.Document == "" && len(p.Arns) == 0
+}
+
+// Check checks p against the limits of STS.
+func (p Policy) Check() error {
+	if p.Document != "" {
+		if _, err := Parse([]byte(p.Document)); err != nil {
+			return err
+		}
+	}
+	if len(p.Arns) > MaxArns {
+		return fmt.Errorf("%d policy ARNs, at most %d are allowed", len(p.Arns), MaxArns)
+	}
+	for _, arn := range p.Arns {
+		if !arnPattern.MatchString(arn) {
+			return fmt.Errorf("invalid policy ARN %q", arn)
+		}
+	}
+	return nil
+}
+
+// document is the part of a policy document checked locally.
+type document struct {
+	Version   string          `json:"Version,omitempty"`
+	Statement json.RawMessage `json:"Statement"`
+}
+
+// Parse checks that data is a policy document of at most MaxSize characters
+// and returns it without whitespace.
+func Parse(data []byte) (string, error) {
+	var doc document
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return "", fmt.Errorf("invalid policy JSON: %w", err)
+	}
+	if doc.Version != "" && doc.Version != "2012-10-17" && doc.Version != "2008-10-17" {
+		return "", fmt.Errorf("unknown policy version %q", doc.Version)
+	}
+	if _, err := statements(doc.Statement); err != nil {
+		return "", err
+	}
+
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, data); err != nil {
+		return "", err
+	}
+	if n := utf8.RuneCount(compact.Bytes()); n > MaxSize {
+		return "", fmt.Errorf("policy has %d characters without whitespace, at most %d are allowed", n, MaxSize)
+	}
+	return compact.String(), nil
+}
+
+// statements returns the statements of a document, given as a list or as a
+// single object.
+func statements(raw json.RawMessage) ([]json.RawMessage, error) {
+	raw = bytes.TrimSpace(raw)
+	switch {
+	case len(raw) == 0 || bytes.Equal(raw, []byte("null")):
+		return nil, errors.New("policy has no Statement")
+	case raw[0] == '{':
+		return []json.RawMessage{raw}, nil
+	}
+
+	var list []json.RawMessage
+	if err := json.Unmarshal(raw, &list); err != nil {
+		return nil, errors.New("policy Statement must be an object or a list of objects")
+	}
+	if len(list) == 0 {
+		return nil, errors.New("policy has no Statement")
+	}
+	return list, nil
+}
+
+// Merge combines the statements of documents into one document.
+func Merge(documents ...string) (string, error) {
+	merged := []json.RawMessage{}
+	for _, d := range documents {
+		if d == "" {
+			continue
+		}
+		var doc document
+		if err := json.Unmarshal([]byte(d), &doc); err != nil {
+			return "", fmt.Errorf("invalid policy JSON: %w", err)
+		}
+		list, err := statements(doc.Statement)
+		if err != nil {
+			return "", err
+		}
+		merged = append(merged, list...)
+	}
+	if len(merged) == 0 {
+		return "", nil
+	}
+
+	b, err := json.Marshal(map[string]any{"Version": "2012-10-17", "Statement": merged})
+	if err != nil {
+		return "", err
+	}
+	return Parse(b)
+}
+
+// presets build policies for a partition and an optional argument.
+var presets = map[string]struct {
+	usage  string
+	policy func(partition, arg string) (Policy, error)
+}{
+	"read-only": {
+		usage: "read-only",
+		policy: func(partition, arg string) (Policy, error) {
+			return Policy{Arns: []string{"arn:" + partition + ":iam::aws:policy/ReadOnlyAccess"}}, nil
+		},
+	},
+	"view-only": {
+		usage: "view-only",
+		policy: func(partition, arg string) (Policy, error) {
+			return Policy{Arns: []string{"arn:" + partition + ":iam::aws:policy/job-function/ViewOnlyAccess"}}, nil
+		},
+	},
+	"s3-bucket-only": {
+		usage:  "s3-bucket-only:BUCKET",
+		policy: bucketOnly,
+	},
+}
+
+var bucketPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9.-]{1,61}[a-z0-9]$`)
+
+// bucketOnly allows all S3 actions on a single bucket and its objects.
+func bucketOnly(partition, bucket string) (Policy, error) {
+	if !bucketPattern.MatchString(bucket) {
+		return Policy{}, fmt.Errorf("invalid bucket name %q", bucket)
+	}
+	bucketArn := "arn:" + partition + ":s3:::" + bucket
+	b, err := json.Marshal(map[string]any{
+		"Version": "2012-10-17",
+		"Statement": []map[string]any{{
+			"Effect":   "Allow",
+			"Action":   "s3:*",
+			"Resource": []string{bucketArn, bucketArn + "/*"},
+		}},
+	})
+	if err != nil {
+		return Policy{}, err
+	}
+	return Policy{Document: string(b)}, nil
+}
+
+// Preset returns the policy of a preset given as NAME or NAME:ARGUMENT for
+// partition.
+func Preset(preset, partition string) (Policy, error) {
+	name, arg, _ := strings.Cut(preset, ":")
+	p, ok := presets[name]
+	if !ok {
+		return Policy{}, fmt.Errorf("unknown policy preset %q, expected one of: %s", name, strings.Join(Presets(), ", "))
+	}
+	if (arg == "") == strings.Contains(p.usage, ":") {
+		return Policy{}, fmt.Errorf("policy preset is used as %s", p.usage)
+	}
+	return p.policy(partition, arg)
+}
+
+// Presets returns the usage of all presets.
+func Presets() []string {
+	names := make([]string, 0, len(presets))
+	for _, p := range presets {
+		names = append(names, p.usage)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,112 @@
+package policy
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{
+			value: "{\n  \"Version\": \"2012-10-17\",\n  \"Statement\": [{\"Effect\": \"Allow\", \"Action\": \"s3:GetObject\", \"Resource\": \"*\"}]\n}\n",
+			want:  `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":"s3:GetObject","Resource":"*"}]}`,
+		},
+		{value: `{"Statement": {"Effect": "Allow", "Action": "*", "Resource": "*"}}`, want: `{"Statement":{"Effect":"Allow","Action":"*","Resource":"*"}}`},
+		{value: `{"Statement": [{"Effect": "Allow",}]}`, wantErr: true},
+		{value: `{"Version": "2012-10-17"}`, wantErr: true},
+		{value: `{"Statement": []}`, wantErr: true},
+		{value: `{"Statement": "Allow"}`, wantErr: true},
+		{value: `{"Version": "2020-01-01", "Statement": {}}`, wantErr: true},
+		{value: `{"Statement": {"Effect": "Allow", "Action": "*", "Resource": "` + strings.Repeat("x", MaxSize) + `"}}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := Parse([]byte(tt.value))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("err is wrong, value=%v, wantErr=%v, err=%v", tt.value, tt.wantErr, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("got %s, expected %s", got, tt.want)
+		}
+	}
+}
+
+func TestMerge(t *testing.T) {
+	got, err := Merge(
+		`{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":"s3:*","Resource":"*"}]}`,
+		"",
+		`{"Statement":{"Effect":"Deny","Action":"s3:DeleteObject","Resource":"*"}}`,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"Statement":[{"Effect":"Allow","Action":"s3:*","Resource":"*"},{"Effect":"Deny","Action":"s3:DeleteObject","Resource":"*"}],"Version":"2012-10-17"}`
+	if got != want {
+		t.Errorf("got %s, expected %s", got, want)
+	}
+	if got, err := Merge(); got != "" || err != nil {
+		t.Errorf("got %q, %v for no documents", got, err)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	arns := []string{}
+	for i := 0; i <= MaxArns; i++ {
+		arns = append(arns, "arn:aws:iam::aws:policy/ReadOnlyAccess")
+	}
+
+	tests := []struct {
+		value   Policy
+		wantErr bool
+	}{
+		{value: Policy{}},
+		{value: Policy{Arns: []string{"arn:aws-us-gov:iam::111111111111:policy/path/team-read"}}},
+		{value: Policy{Arns: []string{"ReadOnlyAccess"}}, wantErr: true},
+		{value: Policy{Arns: arns}, wantErr: true},
+		{value: Policy{Document: "{"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		if err := tt.value.Check(); (err != nil) != tt.wantErr {
+			t.Errorf("err is wrong, value=%v, wantErr=%v, err=%v", tt.value, tt.wantErr, err)
+		}
+	}
+}
+
+func TestPreset(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    Policy
+		wantErr bool
+	}{
+		{value: "read-only", want: Policy{Arns: []string{"arn:aws-cn:iam::aws:policy/ReadOnlyAccess"}}},
+		{value: "view-only", want: Policy{Arns: []string{"arn:aws-cn:iam::aws:policy/job-function/ViewOnlyAccess"}}},
+		{
+			value: "s3-bucket-only:reports",
+			want:  Policy{Document: `{"Statement":[{"Action":"s3:*","Effect":"Allow","Resource":["arn:aws-cn:s3:::reports","arn:aws-cn:s3:::reports/*"]}],"Version":"2012-10-17"}`},
+		},
+		{value: "s3-bucket-only", wantErr: true},
+		{value: "s3-bucket-only:Not_A_Bucket", wantErr: true},
+		{value: "read-only:x", wantErr: true},
+		{value: "admin", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := Preset(tt.value, "aws-cn")
+		if (err != nil) != tt.wantErr {
+			t.Errorf("err is wrong, value=%v, wantErr=%v, err=%v", tt.value, tt.wantErr, err)
+			continue
+		}
+		if err == nil && (got.Document != tt.want.Document || strings.Join(got.Arns, ",") != strings.Join(tt.want.Arns, ",")) {
+			t.Errorf("%s: got %+v, expected %+v", tt.value, got, tt.want)
+		}
+		if err == nil && got.Check() != nil {
+			t.Errorf("%s: preset fails its own check: %v", tt.value, got.Check())
+		}
+	}
+}
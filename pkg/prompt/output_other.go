@@ -0,0 +1,11 @@
+//go:build !windows
+
+package prompt
+
+import "os"
+
+// Output opens the terminal to draw on. On Unix it is the same device input
+// is read from.
+func Output() (*os.File, error) {
+	return Terminal()
+}
@@ -0,0 +1,27 @@
+//go:build windows
+
+package prompt
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// Output opens the terminal to draw on. CONIN$ only reads input on Windows,
+// output goes to the console screen buffer, which has to process the escape
+// sequences of the picker.
+func Output() (*os.File, error) {
+	f, err := os.OpenFile("CONOUT$", os.O_RDWR, 0)
+	if err != nil {
+		return nil, fmt.Errorf("no terminal to draw on: %w", err)
+	}
+
+	h := windows.Handle(f.Fd())
+	var mode uint32
+	if err := windows.GetConsoleMode(h, &mode); err == nil {
+		_ = windows.SetConsoleMode(h, mode|windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING)
+	}
+	return f, nil
+}
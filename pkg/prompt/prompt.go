@@ -0,0 +1,85 @@
+package prompt
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// Terminal opens the controlling terminal, so prompts work while stdout is
+// captured by eval $(aws-login). On Windows it is the console input, which
+// can not be written to, see Output.
+func Terminal() (*os.File, error) {
+	name := "/dev/tty"
+	if runtime.GOOS == "windows" {
+		name = "CONIN$"
+	}
+
+	f, err := os.OpenFile(name, os.O_RDWR, 0)
+	if err != nil {
+		return nil, fmt.Errorf("no terminal to prompt on: %w", err)
+	}
+	return f, nil
+}
+
+// Password writes prompt to stderr and reads a line from the terminal without
+// echoing it.
+func Password(prompt string) ([]byte, error) {
+	f, err := Terminal()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	if _, err := fmt.Fprint(os.Stderr, prompt); err != nil {
+		return nil, err
+	}
+	b, err := term.ReadPassword(int(f.Fd())) // #nosec G115 -- file descriptors fit into int
+	_, _ = fmt.Fprintln(os.Stderr)
+	return b, err
+}
+
+// Line writes prompt to stderr and reads a line from the terminal.
+func Line(prompt string) (string, error) {
+	f, err := Terminal()
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = f.Close() }()
+
+	if _, err := fmt.Fprint(os.Stderr, prompt); err != nil {
+		return "", err
+	}
+	return ReadLine(f)
+}
+
+// ReadLine reads a single line from r without the line ending. It reads one
+// byte at a time, so the rest of r is left for whoever reads it next.
+func ReadLine(r io.Reader) (string, error) {
+	line := []byte{}
+	b := make([]byte, 1)
+	for {
+		n, err := r.Read(b)
+		if n == 1 {
+			if b[0] == '\n' {
+				break
+			}
+			line = append(line, b[0])
+		}
+		if errors.Is(err, io.EOF) {
+			if len(line) == 0 {
+				return "", io.ErrUnexpectedEOF
+			}
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	return strings.TrimSuffix(string(line), "\r"), nil
+}
@@ -0,0 +1,38 @@
+package prompt
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReadLine(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		wantErr  bool
+	}{
+		{input: "123456\nrest", expected: "123456"},
+		{input: "123456\r\n", expected: "123456"},
+		{input: "123456", expected: "123456"},
+		{input: "\n", expected: ""},
+		{input: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ReadLine(strings.NewReader(tt.input))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("err is wrong, value=%q, wantErr=%v, err=%v", tt.input, tt.wantErr, err)
+			continue
+		}
+		if got != tt.expected {
+			t.Errorf("ReadLine(%q) = %q, expected %q", tt.input, got, tt.expected)
+		}
+	}
+
+	// the rest is left unread
+	r := strings.NewReader("123456\nrest")
+	_, _ = ReadLine(r)
+	if r.Len() != 4 {
+		t.Errorf("ReadLine consumed %d bytes too many", 4-r.Len())
+	}
+}
@@ -0,0 +1,80 @@
+package sso
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sso"
+)
+
+// Portal is the part of the SSO API listing account assignments, implemented
+// by *sso.Client.
+type Portal interface {
+	ListAccounts(ctx context.Context, params *sso.ListAccountsInput, optFns ...func(*sso.Options)) (*sso.ListAccountsOutput, error)
+	ListAccountRoles(ctx context.Context, params *sso.ListAccountRolesInput, optFns ...func(*sso.Options)) (*sso.ListAccountRolesOutput, error)
+}
+
+// Role is a role the user can get credentials for.
+type Role struct {
+	AccountID   string
+	AccountName string
+	RoleName    string
+}
+
+// Roles lists the roles of every account assigned to the owner of token.
+func Roles(ctx context.Context, client Portal, token *Token) ([]Role, error) {
+	roles := []Role{}
+
+	var next *string
+	for {
+		accounts, err := client.ListAccounts(ctx, &sso.ListAccountsInput{
+			AccessToken: aws.String(token.AccessToken),
+			NextToken:   next,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, account := range accounts.AccountList {
+			accountRoles, err := accountRoles(ctx, client, token, aws.ToString(account.AccountId))
+			if err != nil {
+				return nil, err
+			}
+			for _, name := range accountRoles {
+				roles = append(roles, Role{
+					AccountID:   aws.ToString(account.AccountId),
+					AccountName: aws.ToString(account.AccountName),
+					RoleName:    name,
+				})
+			}
+		}
+
+		if next = accounts.NextToken; aws.ToString(next) == "" {
+			return roles, nil
+		}
+	}
+}
+
+func accountRoles(ctx context.Context, client Portal, token *Token, account string) ([]string, error) {
+	names := []string{}
+
+	var next *string
+	for {
+		roles, err := client.ListAccountRoles(ctx, &sso.ListAccountRolesInput{
+			AccessToken: aws.String(token.AccessToken),
+			AccountId:   aws.String(account),
+			NextToken:   next,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, role := range roles.RoleList {
+			names = append(names, aws.ToString(role.RoleName))
+		}
+
+		if next = roles.NextToken; aws.ToString(next) == "" {
+			return names, nil
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package sso
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssooidc"
+	"github.com/aws/aws-sdk-go-v2/service/ssooidc/types"
+)
+
+// ClientName is the name the OIDC client is registered under.
+const ClientName = "aws-login"
+
+const (
+	grantDeviceCode   = "urn:ietf:params:oauth:grant-type:device_code"
+	grantRefreshToken = "refresh_token"
+
+	// scopeAccountAccess lets the access token list accounts and get role
+	// credentials.
+	scopeAccountAccess = "sso:account:access"
+)
+
+// OIDC is the part of the SSO OIDC API used to log in, implemented by
+// *ssooidc.Client.
+type OIDC interface {
+	RegisterClient(ctx context.Context, params *ssooidc.RegisterClientInput, optFns ...func(*ssooidc.Options)) (*ssooidc.RegisterClientOutput, error)
+	StartDeviceAuthorization(ctx context.Context, params *ssooidc.StartDeviceAuthorizationInput, optFns ...func(*ssooidc.Options)) (*ssooidc.StartDeviceAuthorizationOutput, error)
+	CreateToken(ctx context.Context, params *ssooidc.CreateTokenInput, optFns ...func(*ssooidc.Options)) (*ssooidc.CreateTokenOutput, error)
+}
+
+// Authorization is what the user has to confirm in the browser.
+type Authorization struct {
+	UserCode                string
+	VerificationURI         string
+	VerificationURIComplete string
+}
+
+// sleep waits between polls, replaced in tests.
+var sleep = func(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// Refresh exchanges the refresh token of previous for a new access token.
+func Refresh(ctx context.Context, client OIDC, previous *Token) (*Token, error) {
+	if previous.RefreshToken == "" || !previous.registered() {
+		return nil, errors.New("no refresh token")
+	}
+
+	out, err := client.CreateToken(ctx, &ssooidc.CreateTokenInput{
+		ClientId:     aws.String(previous.ClientID),
+		ClientSecret: aws.String(previous.ClientSecret),
+		GrantType:    aws.String(grantRefreshToken),
+		RefreshToken: aws.String(previous.RefreshToken),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	t := *previous
+	t.update(out)
+	return &t, nil
+}
+
+// Login runs the OAuth device authorization flow for startURL. The client
+// registration of previous is reused while it is valid and got a refresh
+// token, registrations without the refresh grant are replaced. authorize is
+// called with the code to confirm, then CreateToken is polled until the user
+// has done so.
+func Login(ctx context.Context, client OIDC, previous *Token, startURL, region string, authorize func(Authorization)) (*Token, error) {
+	t := &Token{StartURL: startURL, Region: region}
+	if previous != nil && previous.StartURL == startURL && previous.registered() && previous.RefreshToken != "" {
+		t.ClientID, t.ClientSecret, t.RegistrationExpiresAt = previous.ClientID, previous.ClientSecret, previous.RegistrationExpiresAt
+	} else {
+		reg, err := client.RegisterClient(ctx, &ssooidc.RegisterClientInput{
+			ClientName: aws.String(ClientName),
+			ClientType: aws.String("public"),
+			Scopes:     []string{scopeAccountAccess},
+			GrantTypes: []string{grantDeviceCode, grantRefreshToken},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("can not register OIDC client: %w", err)
+		}
+		t.ClientID = aws.ToString(reg.ClientId)
+		t.ClientSecret = aws.ToString(reg.ClientSecret)
+		t.RegistrationExpiresAt = time.Unix(reg.ClientSecretExpiresAt, 0).UTC()
+	}
+
+	auth, err := client.StartDeviceAuthorization(ctx, &ssooidc.StartDeviceAuthorizationInput{
+		ClientId:     aws.String(t.ClientID),
+		ClientSecret: aws.String(t.ClientSecret),
+		StartUrl:     aws.String(startURL),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("can not start device authorization: %w", err)
+	}
+
+	authorize(Authorization{
+		UserCode:                aws.ToString(auth.UserCode),
+		VerificationURI:         aws.ToString(auth.VerificationUri),
+		VerificationURIComplete: aws.ToString(auth.VerificationUriComplete),
+	})
+
+	interval := time.Duration(max(auth.Interval, 1)) * time.Second
+	deadline := time.Now().Add(time.Duration(auth.ExpiresIn) * time.Second)
+	for {
+		if err := sleep(ctx, interval); err != nil {
+			return nil, err
+		}
+
+		out, err := client.CreateToken(ctx, &ssooidc.CreateTokenInput{
+			ClientId:     aws.String(t.ClientID),
+			ClientSecret: aws.String(t.ClientSecret),
+			GrantType:    aws.String(grantDeviceCode),
+			DeviceCode:   auth.DeviceCode,
+		})
+
+		var pending *types.AuthorizationPendingException
+		var slowDown *types.SlowDownException
+		switch {
+		case err == nil:
+			t.update(out)
+			return t, nil
+		case errors.As(err, &slowDown):
+			interval += 5 * time.Second
+		case !errors.As(err, &pending):
+			return nil, err
+		}
+
+		if auth.ExpiresIn > 0 && time.Now().After(deadline) {
+			return nil, errors.New("device authorization expired before it was confirmed")
+		}
+	}
+}
+
+func (t *Token) update(out *ssooidc.CreateTokenOutput) {
+	t.AccessToken = aws.ToString(out.AccessToken)
+	t.ExpiresAt = time.Now().Add(time.Duration(out.ExpiresIn) * time.Second).UTC().Truncate(time.Second)
+	if out.RefreshToken != nil {
+		t.RefreshToken = aws.ToString(out.RefreshToken)
+	}
+}
@@ -0,0 +1,202 @@
+package sso
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sso"
+	ssotypes "github.com/aws/aws-sdk-go-v2/service/sso/types"
+	"github.com/aws/aws-sdk-go-v2/service/ssooidc"
+	"github.com/aws/aws-sdk-go-v2/service/ssooidc/types"
+)
+
+type fakeOIDC struct {
+	registrations int
+	registration  *ssooidc.RegisterClientInput
+	pending       int
+	slowDown      bool
+	grants        []string
+}
+
+func (f *fakeOIDC) RegisterClient(ctx context.Context, params *ssooidc.RegisterClientInput, optFns ...func(*ssooidc.Options)) (*ssooidc.RegisterClientOutput, error) {
+	f.registrations++
+	f.registration = params
+	return &ssooidc.RegisterClientOutput{
+		ClientId:              aws.String("client"),
+		ClientSecret:          aws.String("secret"),
+		ClientSecretExpiresAt: time.Now().Add(90 * 24 * time.Hour).Unix(),
+	}, nil
+}
+
+func (f *fakeOIDC) StartDeviceAuthorization(ctx context.Context, params *ssooidc.StartDeviceAuthorizationInput, optFns ...func(*ssooidc.Options)) (*ssooidc.StartDeviceAuthorizationOutput, error) {
+	return &ssooidc.StartDeviceAuthorizationOutput{
+		DeviceCode:              aws.String("device"),
+		UserCode:                aws.String("ABCD-EFGH"),
+		VerificationUri:         aws.String("https://device.sso.eu-west-1.amazonaws.com/"),
+		VerificationUriComplete: aws.String("https://device.sso.eu-west-1.amazonaws.com/?user_code=ABCD-EFGH"),
+		ExpiresIn:               600,
+		Interval:                1,
+	}, nil
+}
+
+func (f *fakeOIDC) CreateToken(ctx context.Context, params *ssooidc.CreateTokenInput, optFns ...func(*ssooidc.Options)) (*ssooidc.CreateTokenOutput, error) {
+	f.grants = append(f.grants, aws.ToString(params.GrantType))
+	if f.slowDown {
+		f.slowDown = false
+		return nil, &types.SlowDownException{}
+	}
+	if f.pending > 0 {
+		f.pending--
+		return nil, &types.AuthorizationPendingException{}
+	}
+	return &ssooidc.CreateTokenOutput{
+		AccessToken:  aws.String("access"),
+		RefreshToken: aws.String("refresh"),
+		ExpiresIn:    3600,
+	}, nil
+}
+
+func TestLogin(t *testing.T) {
+	waited := time.Duration(0)
+	sleep = func(ctx context.Context, d time.Duration) error {
+		waited += d
+		return nil
+	}
+
+	client := &fakeOIDC{pending: 2, slowDown: true}
+	var authorization Authorization
+	token, err := Login(context.Background(), client, nil, "https://corp.awsapps.com/start", "eu-west-1", func(a Authorization) { authorization = a })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if authorization.UserCode != "ABCD-EFGH" {
+		t.Errorf("got authorization %+v", authorization)
+	}
+	if token.AccessToken != "access" || token.RefreshToken != "refresh" || token.ClientID != "client" || !token.Valid(time.Minute) {
+		t.Errorf("got token %+v", token)
+	}
+	// 4 polls, the interval grows by 5 seconds after SlowDown
+	if len(client.grants) != 4 || waited != 1*time.Second+3*6*time.Second {
+		t.Errorf("got %d polls waiting %s", len(client.grants), waited)
+	}
+
+	// the client registration is reused
+	if _, err := Login(context.Background(), client, token, token.StartURL, token.Region, func(Authorization) {}); err != nil {
+		t.Fatal(err)
+	}
+	if client.registrations != 1 {
+		t.Errorf("got %d registrations", client.registrations)
+	}
+	if !reflect.DeepEqual(client.registration.Scopes, []string{"sso:account:access"}) || !reflect.DeepEqual(client.registration.GrantTypes, []string{grantDeviceCode, grantRefreshToken}) {
+		t.Errorf("registered scopes %v and grants %v", client.registration.Scopes, client.registration.GrantTypes)
+	}
+
+	// a registration that never got a refresh token is replaced
+	withoutRefresh := *token
+	withoutRefresh.RefreshToken = ""
+	if _, err := Login(context.Background(), client, &withoutRefresh, token.StartURL, token.Region, func(Authorization) {}); err != nil {
+		t.Fatal(err)
+	}
+	if client.registrations != 2 {
+		t.Errorf("got %d registrations", client.registrations)
+	}
+
+	refreshed, err := Refresh(context.Background(), client, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if refreshed.AccessToken != "access" || client.grants[len(client.grants)-1] != grantRefreshToken {
+		t.Errorf("got token %+v after grants %v", refreshed, client.grants)
+	}
+
+	if _, err := Refresh(context.Background(), client, &Token{AccessToken: "access"}); err == nil {
+		t.Error("refresh without refresh token should fail")
+	}
+}
+
+func TestLoginCanceled(t *testing.T) {
+	sleep = func(ctx context.Context, d time.Duration) error { return ctx.Err() }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := Login(ctx, &fakeOIDC{pending: 1}, nil, "https://corp.awsapps.com/start", "eu-west-1", func(Authorization) {})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("got err %v", err)
+	}
+}
+
+func TestTokenCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sso", "cache", "token.json")
+
+	token, err := LoadToken(path)
+	if token != nil || err != nil {
+		t.Fatalf("got %+v, %v for a missing token", token, err)
+	}
+
+	expected := &Token{
+		StartURL:              "https://corp.awsapps.com/start",
+		Region:                "eu-west-1",
+		AccessToken:           "access",
+		ExpiresAt:             time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC),
+		ClientID:              "client",
+		ClientSecret:          "secret",
+		RegistrationExpiresAt: time.Date(2030, 3, 2, 3, 4, 5, 0, time.UTC),
+	}
+	if err := SaveToken(path, expected); err != nil {
+		t.Fatal(err)
+	}
+
+	token, err = LoadToken(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *token != *expected {
+		t.Errorf("got %+v but expected %+v", token, expected)
+	}
+}
+
+type fakePortal struct{}
+
+func (fakePortal) ListAccounts(ctx context.Context, params *sso.ListAccountsInput, optFns ...func(*sso.Options)) (*sso.ListAccountsOutput, error) {
+	if aws.ToString(params.NextToken) == "" {
+		return &sso.ListAccountsOutput{
+			AccountList: []ssotypes.AccountInfo{{AccountId: aws.String("111111111111"), AccountName: aws.String("production")}},
+			NextToken:   aws.String("page2"),
+		}, nil
+	}
+	return &sso.ListAccountsOutput{
+		AccountList: []ssotypes.AccountInfo{{AccountId: aws.String("222222222222"), AccountName: aws.String("staging")}},
+	}, nil
+}
+
+func (fakePortal) ListAccountRoles(ctx context.Context, params *sso.ListAccountRolesInput, optFns ...func(*sso.Options)) (*sso.ListAccountRolesOutput, error) {
+	if aws.ToString(params.AccountId) == "222222222222" {
+		return &sso.ListAccountRolesOutput{RoleList: []ssotypes.RoleInfo{{RoleName: aws.String("Developer")}}}, nil
+	}
+	if aws.ToString(params.NextToken) == "" {
+		return &sso.ListAccountRolesOutput{RoleList: []ssotypes.RoleInfo{{RoleName: aws.String("Admin")}}, NextToken: aws.String("page2")}, nil
+	}
+	return &sso.ListAccountRolesOutput{RoleList: []ssotypes.RoleInfo{{RoleName: aws.String("ReadOnly")}}}, nil
+}
+
+func TestRoles(t *testing.T) {
+	roles, err := Roles(context.Background(), fakePortal{}, &Token{AccessToken: "access"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Role{
+		{AccountID: "111111111111", AccountName: "production", RoleName: "Admin"},
+		{AccountID: "111111111111", AccountName: "production", RoleName: "ReadOnly"},
+		{AccountID: "222222222222", AccountName: "staging", RoleName: "Developer"},
+	}
+	if !reflect.DeepEqual(roles, expected) {
+		t.Errorf("got %+v but expected %+v", roles, expected)
+	}
+}
@@ -0,0 +1,87 @@
+package sso
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/credentials/ssocreds"
+)
+
+// Token is an IAM Identity Center access token together with the client
+// registration it was issued to. It is stored in the layout of the AWS CLI,
+// so both tools share a login.
+type Token struct {
+	StartURL              string    `json:"startUrl"`
+	Region                string    `json:"region"`
+	AccessToken           string    `json:"accessToken"`
+	ExpiresAt             time.Time `json:"expiresAt"`
+	RefreshToken          string    `json:"refreshToken,omitempty"`
+	ClientID              string    `json:"clientId,omitempty"`
+	ClientSecret          string    `json:"clientSecret,omitempty"`
+	RegistrationExpiresAt time.Time `json:"registrationExpiresAt"`
+}
+
+// Valid reports whether the access token is usable for at least window.
+func (t *Token) Valid(window time.Duration) bool {
+	return t.AccessToken != "" && time.Until(t.ExpiresAt) > window
+}
+
+// registered reports whether the client registration can still be used.
+func (t *Token) registered() bool {
+	return t.ClientID != "" && t.ClientSecret != "" && time.Until(t.RegistrationExpiresAt) > time.Minute
+}
+
+// CachePath returns where the token of key, an sso-session name or a start
+// URL, is stored: ~/.aws/sso/cache/SHA1.json.
+func CachePath(key string) (string, error) {
+	return ssocreds.StandardCachedTokenFilepath(key)
+}
+
+// LoadToken reads a cached token. It returns nil when there is none.
+func LoadToken(path string) (*Token, error) {
+	b, err := os.ReadFile(filepath.Clean(path))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	t := &Token{}
+	if err := json.Unmarshal(b, t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// SaveToken writes t to a 0600 file at path, replacing it atomically.
+func SaveToken(path string, t *Token) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, ".token.*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if _, err := tmp.Write(b); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
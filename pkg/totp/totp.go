@@ -0,0 +1,148 @@
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/sha1" // #nosec G505 -- SHA1 is the RFC 6238 default used by virtual MFA devices
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Key is a TOTP seed together with its RFC 6238 parameters.
+type Key struct {
+	Secret    []byte
+	Algorithm string
+	Digits    int
+	Period    int
+}
+
+// ParseURI parses an otpauth://totp/ URI as exported by virtual MFA devices.
+func ParseURI(uri string) (*Key, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "otpauth" || u.Host != "totp" {
+		return nil, errors.New("expected an otpauth://totp/ URI")
+	}
+
+	q := u.Query()
+	secret, err := DecodeSecret(q.Get("secret"))
+	if err != nil {
+		return nil, err
+	}
+
+	k := &Key{Secret: secret, Algorithm: "SHA1", Digits: 6, Period: 30}
+	if v := q.Get("algorithm"); v != "" {
+		k.Algorithm = strings.ToUpper(v)
+	}
+	if v := q.Get("digits"); v != "" {
+		if k.Digits, err = strconv.Atoi(v); err != nil {
+			return nil, fmt.Errorf("invalid digits %q", v)
+		}
+	}
+	if v := q.Get("period"); v != "" {
+		if k.Period, err = strconv.Atoi(v); err != nil {
+			return nil, fmt.Errorf("invalid period %q", v)
+		}
+	}
+
+	return k, k.validate()
+}
+
+// DecodeSecret decodes a base32 secret, ignoring case, spaces and padding.
+func DecodeSecret(secret string) ([]byte, error) {
+	secret = strings.ToUpper(strings.ReplaceAll(secret, " ", ""))
+	secret = strings.TrimRight(secret, "=")
+	if secret == "" {
+		return nil, errors.New("missing secret")
+	}
+
+	b, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil {
+		return nil, fmt.Errorf("invalid secret: %w", err)
+	}
+	return b, nil
+}
+
+func (k *Key) validate() error {
+	if _, err := k.hash(); err != nil {
+		return err
+	}
+	if k.Digits < 6 || k.Digits > 8 {
+		return fmt.Errorf("unsupported number of digits %d", k.Digits)
+	}
+	if k.Period <= 0 {
+		return fmt.Errorf("invalid period %d", k.Period)
+	}
+	return nil
+}
+
+func (k *Key) hash() (func() hash.Hash, error) {
+	switch k.Algorithm {
+	case "SHA1":
+		return sha1.New, nil
+	case "SHA256":
+		return sha256.New, nil
+	case "SHA512":
+		return sha512.New, nil
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %q", k.Algorithm)
+	}
+}
+
+// Step returns the time step t falls into.
+func (k *Key) Step(t time.Time) int64 {
+	return t.Unix() / int64(k.Period)
+}
+
+// Code returns the code of a time step.
+func (k *Key) Code(step int64) (string, error) {
+	h, err := k.hash()
+	if err != nil {
+		return "", err
+	}
+
+	counter := make([]byte, 8)
+	binary.BigEndian.PutUint64(counter, uint64(step)) // #nosec G115 -- steps are never negative
+
+	mac := hmac.New(h, k.Secret)
+	mac.Write(counter)
+	sum := mac.Sum(nil)
+
+	// dynamic truncation, RFC 4226 section 5.3
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < k.Digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", k.Digits, value%mod), nil
+}
+
+// Next picks the step to generate a code for at time now. The current step
+// is skipped when its code was already used, as STS rejects a code it has
+// accepted before, or when it expires within margin and would likely be
+// stale by the time it reaches STS. It returns the step and how long to wait
+// until it starts.
+func (k *Key) Next(now time.Time, lastUsed int64, margin time.Duration) (int64, time.Duration) {
+	step := k.Step(now)
+	if time.Unix((step+1)*int64(k.Period), 0).Sub(now) < margin {
+		step++
+	}
+	if step <= lastUsed {
+		step = lastUsed + 1
+	}
+
+	wait := time.Unix(step*int64(k.Period), 0).Sub(now)
+	return step, max(wait, 0)
+}
@@ -0,0 +1,94 @@
+package totp
+
+import (
+	"testing"
+	"time"
+)
+
+// test vectors from RFC 6238 appendix B
+func TestCode(t *testing.T) {
+	keys := map[string]*Key{
+		"SHA1":   {Secret: []byte("12345678901234567890"), Algorithm: "SHA1", Digits: 8, Period: 30},
+		"SHA256": {Secret: []byte("12345678901234567890123456789012"), Algorithm: "SHA256", Digits: 8, Period: 30},
+		"SHA512": {Secret: []byte("1234567890123456789012345678901234567890123456789012345678901234"), Algorithm: "SHA512", Digits: 8, Period: 30},
+	}
+
+	tests := []struct {
+		unix      int64
+		algorithm string
+		want      string
+	}{
+		{59, "SHA1", "94287082"},
+		{59, "SHA256", "46119246"},
+		{59, "SHA512", "90693936"},
+		{1111111109, "SHA1", "07081804"},
+		{1234567890, "SHA256", "91819424"},
+		{20000000000, "SHA512", "47863826"},
+	}
+
+	for _, test := range tests {
+		k := keys[test.algorithm]
+		got, err := k.Code(k.Step(time.Unix(test.unix, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != test.want {
+			t.Errorf("unix=%v, algorithm=%v: got %s, expected %s", test.unix, test.algorithm, got, test.want)
+		}
+	}
+}
+
+func TestParseURI(t *testing.T) {
+	tests := []struct {
+		uri     string
+		want    Key
+		wantErr bool
+	}{
+		{
+			uri:  "otpauth://totp/Amazon%20Web%20Services:alice@111111111111?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&issuer=Amazon%20Web%20Services",
+			want: Key{Secret: []byte("12345678901234567890"), Algorithm: "SHA1", Digits: 6, Period: 30},
+		},
+		{
+			uri:  "otpauth://totp/x?secret=gezdgnbvgy3tqojqgezdgnbvgy3tqojq&algorithm=sha256&digits=8&period=60",
+			want: Key{Secret: []byte("12345678901234567890"), Algorithm: "SHA256", Digits: 8, Period: 60},
+		},
+		{uri: "otpauth://hotp/x?secret=GEZDGNBV", wantErr: true},
+		{uri: "otpauth://totp/x", wantErr: true},
+		{uri: "otpauth://totp/x?secret=GEZDGNBV&algorithm=MD5", wantErr: true},
+		{uri: "otpauth://totp/x?secret=GEZDGNBV&digits=4", wantErr: true},
+	}
+
+	for _, test := range tests {
+		got, err := ParseURI(test.uri)
+		if (test.wantErr && err == nil) || !test.wantErr && err != nil {
+			t.Errorf("err is wrong, uri=%v, wantErr=%v, err=%v", test.uri, test.wantErr, err)
+			continue
+		}
+		if !test.wantErr && (string(got.Secret) != string(test.want.Secret) || got.Algorithm != test.want.Algorithm || got.Digits != test.want.Digits || got.Period != test.want.Period) {
+			t.Errorf("uri=%v: got %+v, expected %+v", test.uri, got, test.want)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	k := &Key{Secret: []byte("12345678901234567890"), Algorithm: "SHA1", Digits: 6, Period: 30}
+
+	tests := []struct {
+		now      int64
+		lastUsed int64
+		wantStep int64
+		wantWait time.Duration
+	}{
+		{now: 300, lastUsed: 0, wantStep: 10, wantWait: 0},
+		{now: 310, lastUsed: 10, wantStep: 11, wantWait: 20 * time.Second},
+		{now: 328, lastUsed: 0, wantStep: 11, wantWait: 2 * time.Second},
+		{now: 328, lastUsed: 11, wantStep: 12, wantWait: 32 * time.Second},
+	}
+
+	for _, test := range tests {
+		step, wait := k.Next(time.Unix(test.now, 0), test.lastUsed, 5*time.Second)
+		if step != test.wantStep || wait != test.wantWait {
+			t.Errorf("now=%v, lastUsed=%v: got %v, %v, expected %v, %v", test.now, test.lastUsed, step, wait, test.wantStep, test.wantWait)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package webidentity
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultAudience is the audience requested from token issuers that let the
+// caller choose it, the one expected by the AWS OIDC identity provider.
+const DefaultAudience = "sts.amazonaws.com"
+
+// HTTPClient sends requests to token issuers. *http.Client and the client of
+// aws.Config implement it.
+type HTTPClient interface {
+	Do(*http.Request) (*http.Response, error)
+}
+
+// Source provides an OIDC token.
+type Source struct {
+	// Name describes the source in messages.
+	Name  string
+	token func(ctx context.Context) (string, error)
+}
+
+// Token returns the token of s, checked to be an unexpired JWT.
+func (s Source) Token(ctx context.Context) (string, error) {
+	token, err := s.token(ctx)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", s.Name, err)
+	}
+	token = strings.TrimSpace(token)
+	if err := check(token, time.Now()); err != nil {
+		return "", fmt.Errorf("%s: %w", s.Name, err)
+	}
+	return token, nil
+}
+
+// File reads the token from path, which CI systems and Kubernetes refresh
+// in place.
+func File(path string) Source {
+	return Source{Name: "token file " + path, token: func(context.Context) (string, error) {
+		b, err := os.ReadFile(path) // #nosec G304 -- the token file is chosen by the user
+		return string(b), err
+	}}
+}
+
+// Env reads the token from the environment variable name.
+func Env(name string) Source {
+	return Source{Name: "environment variable " + name, token: func(context.Context) (string, error) {
+		token := os.Getenv(name)
+		if token == "" {
+			return "", errors.New("not set")
+		}
+		return token, nil
+	}}
+}
+
+// Command runs command with the shell and reads the token from its output.
+func Command(command string) Source {
+	return Source{Name: "command " + command, token: func(ctx context.Context) (string, error) {
+		shell, flag := "sh", "-c"
+		if runtime.GOOS == "windows" {
+			shell, flag = "cmd", "/C"
+		}
+		cmd := exec.CommandContext(ctx, shell, flag, command) // #nosec G204 -- running the given command is the purpose of the source
+		cmd.Stderr = os.Stderr
+		out, err := cmd.Output()
+		return string(out), err
+	}}
+}
+
+// GitHubActions requests a token for audience from the OIDC provider of
+// GitHub Actions. The job needs the id-token: write permission. A nil client
+// is replaced by one with a timeout.
+func GitHubActions(client HTTPClient, requestURL, requestToken, audience string) Source {
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	return Source{Name: "GitHub Actions", token: func(ctx context.Context) (string, error) {
+		u, err := url.Parse(requestURL)
+		if err != nil {
+			return "", err
+		}
+		q := u.Query()
+		q.Set("audience", audience)
+		u.RawQuery = q.Encode()
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+		if err != nil {
+			return "", err
+		}
+		req.Header.Set("Authorization", "Bearer "+requestToken)
+		req.Header.Set("Accept", "application/json")
+		resp, err := client.Do(req)
+		if err != nil {
+			return "", err
+		}
+		defer func() { _ = resp.Body.Close() }()
+
+		body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+		if err != nil {
+			return "", err
+		}
+		if resp.StatusCode != http.StatusOK {
+			return "", fmt.Errorf("token request returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+		}
+
+		var token struct {
+			Value string `json:"value"`
+		}
+		if err := json.Unmarshal(body, &token); err != nil {
+			return "", fmt.Errorf("invalid token response: %w", err)
+		}
+		return token.Value, nil
+	}}
+}
+
+// Detect finds the token source of the environment: AWS_WEB_IDENTITY_TOKEN_FILE,
+// the OIDC provider of GitHub Actions, or an ID token of a GitLab CI job, an
+// environment variable ending in _ID_TOKEN.
+func Detect(getenv func(string) string, environ []string, client HTTPClient, audience string) (Source, error) {
+	if path := getenv("AWS_WEB_IDENTITY_TOKEN_FILE"); path != "" {
+		return File(path), nil
+	}
+
+	if requestURL := getenv("ACTIONS_ID_TOKEN_REQUEST_URL"); requestURL != "" {
+		requestToken := getenv("ACTIONS_ID_TOKEN_REQUEST_TOKEN")
+		if requestToken == "" {
+			return Source{}, errors.New("ACTIONS_ID_TOKEN_REQUEST_TOKEN is not set, grant the job the id-token: write permission")
+		}
+		return GitHubActions(client, requestURL, requestToken, audience), nil
+	}
+	if getenv("GITHUB_ACTIONS") == "true" {
+		return Source{}, errors.New("ACTIONS_ID_TOKEN_REQUEST_URL is not set, grant the job the id-token: write permission")
+	}
+
+	names := []string{}
+	for _, kv := range environ {
+		name, value, _ := strings.Cut(kv, "=")
+		if strings.HasSuffix(name, "_ID_TOKEN") && value != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	switch len(names) {
+	case 1:
+		return Env(names[0]), nil
+	case 0:
+		if getenv("GITLAB_CI") == "true" {
+			return Source{}, errors.New("no ID token found, define one with id_tokens in the GitLab CI job")
+		}
+		return Source{}, errors.New("no OIDC token found, set -token-file, -token-env or -token-command")
+	}
+	return Source{}, fmt.Errorf("several ID tokens found, choose one with -token-env: %s", strings.Join(names, ", "))
+}
+
+// check checks that token is a JWT that has not expired at now.
+func check(token string, now time.Time) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" {
+		return errors.New("token is not a JWT")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return fmt.Errorf("token is not a JWT: %w", err)
+	}
+
+	var claims struct {
+		Expiration int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return fmt.Errorf("token is not a JWT: %w", err)
+	}
+	if claims.Expiration != 0 && now.Unix() >= claims.Expiration {
+		return fmt.Errorf("token expired at %s", time.Unix(claims.Expiration, 0).UTC().Format(time.RFC3339))
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package webidentity
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// jwt returns an unsigned token expiring at exp.
+func jwt(exp time.Time) string {
+	payload := fmt.Sprintf(`{"sub":"repo:example/app:ref:refs/heads/main","exp":%d}`, exp.Unix())
+	return "eyJhbGciOiJSUzI1NiJ9." + base64.RawURLEncoding.EncodeToString([]byte(payload)) + ".c2ln"
+}
+
+func TestCheck(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		value   string
+		wantErr bool
+	}{
+		{value: jwt(now.Add(time.Hour))},
+		{value: jwt(now.Add(-time.Minute)), wantErr: true},
+		{value: "eyJhbGciOiJSUzI1NiJ9." + base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"x"}`)) + "."},
+		{value: "not-a-token", wantErr: true},
+		{value: "a.!!.c", wantErr: true},
+		{value: "a." + base64.RawURLEncoding.EncodeToString([]byte("[]")) + ".c", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		if err := check(tt.value, now); (err != nil) != tt.wantErr {
+			t.Errorf("err is wrong, value=%v, wantErr=%v, err=%v", tt.value, tt.wantErr, err)
+		}
+	}
+}
+
+func TestSources(t *testing.T) {
+	token := jwt(time.Now().Add(time.Hour))
+	path := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(path, []byte(token+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TEST_ID_TOKEN", token)
+
+	sources := []Source{File(path), Env("TEST_ID_TOKEN")}
+	if runtime.GOOS != "windows" {
+		sources = append(sources, Command("cat "+path))
+	}
+	for _, s := range sources {
+		got, err := s.Token(context.Background())
+		if err != nil || got != token {
+			t.Errorf("%s: got %q, %v", s.Name, got, err)
+		}
+	}
+
+	if _, err := Env("TEST_UNSET_ID_TOKEN").Token(context.Background()); err == nil {
+		t.Error("expected an error for an unset variable")
+	}
+	if _, err := File(path + ".missing").Token(context.Background()); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
+
+func TestGitHubActions(t *testing.T) {
+	token := jwt(time.Now().Add(time.Hour))
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer request-token" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Query().Get("api-version") != "2.0" || r.URL.Query().Get("audience") != DefaultAudience {
+			http.Error(w, "bad query "+r.URL.RawQuery, http.StatusBadRequest)
+			return
+		}
+		_, _ = w.Write([]byte(`{"count":1,"value":"` + token + `"}`))
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		requestToken string
+		wantErr      bool
+	}{
+		{requestToken: "request-token"},
+		{requestToken: "wrong", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := GitHubActions(srv.Client(), srv.URL+"/token?api-version=2.0", tt.requestToken, DefaultAudience).Token(context.Background())
+		if (err != nil) != tt.wantErr {
+			t.Errorf("err is wrong, value=%v, wantErr=%v, err=%v", tt.requestToken, tt.wantErr, err)
+			continue
+		}
+		if err == nil && got != token {
+			t.Errorf("got %q", got)
+		}
+	}
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		env     map[string]string
+		want    string
+		wantErr bool
+	}{
+		{env: map[string]string{"AWS_WEB_IDENTITY_TOKEN_FILE": "/var/run/token", "GITLAB_ID_TOKEN": "x"}, want: "token file /var/run/token"},
+		{env: map[string]string{"ACTIONS_ID_TOKEN_REQUEST_URL": "https://example.com", "ACTIONS_ID_TOKEN_REQUEST_TOKEN": "t"}, want: "GitHub Actions"},
+		{env: map[string]string{"ACTIONS_ID_TOKEN_REQUEST_URL": "https://example.com"}, wantErr: true},
+		{env: map[string]string{"GITHUB_ACTIONS": "true"}, wantErr: true},
+		{env: map[string]string{"GITLAB_CI": "true", "AWS_ID_TOKEN": "x"}, want: "environment variable AWS_ID_TOKEN"},
+		{env: map[string]string{"GITLAB_CI": "true", "AWS_ID_TOKEN": "x", "VAULT_ID_TOKEN": "y"}, wantErr: true},
+		{env: map[string]string{"GITLAB_CI": "true"}, wantErr: true},
+		{env: map[string]string{}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		environ := []string{}
+		for k, v := range tt.env {
+			environ = append(environ, k+"="+v)
+		}
+		got, err := Detect(func(k string) string { return tt.env[k] }, environ, http.DefaultClient, DefaultAudience)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("err is wrong, value=%v, wantErr=%v, err=%v", tt.env, tt.wantErr, err)
+			continue
+		}
+		if got.Name != tt.want {
+			t.Errorf("%v: got source %q, expected %q", tt.env, got.Name, tt.want)
+		}
+	}
+}
+
+func TestGitHubActionsNilClient(t *testing.T) {
+	token := jwt(time.Now().Add(time.Hour))
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintf(w, `{"value":%q}`, token)
+	}))
+	defer srv.Close()
+
+	got, err := GitHubActions(nil, srv.URL, "request-token", DefaultAudience).Token(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != token {
+		t.Errorf("got %q, expected %q", got, token)
+	}
+}